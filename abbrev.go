@@ -0,0 +1,54 @@
+package treap
+
+import "strings"
+
+// ShortestUniquePrefix returns the shortest prefix of the given value
+// that ResolvePrefix resolves to the value, and true if the value is in
+// the Treap. The prefix is one byte longer than the longest prefix that
+// the value shares with its predecessor or successor, unless that's the
+// whole value, since a value that starts another is resolved only by
+// itself. The neighbors of the value are those in byte order, so in other
+// orders every value is walked.
+func (t *Treap) ShortestUniquePrefix(value string) (string, bool) {
+	if binarySearch(t.root, value, t.order) == nil {
+		return "", false
+	}
+
+	view := t.byteView("")
+	size := 0
+	if pred, ok := view.predecessor(value); ok {
+		size = commonPrefixLen(pred, value) + 1
+	}
+	if succ, ok := view.successor(value); ok {
+		if n := commonPrefixLen(succ, value) + 1; n > size {
+			size = n
+		}
+	}
+	if size > len(value) {
+		size = len(value)
+	}
+
+	return value[:size], true
+}
+
+// ResolvePrefix returns the value in the Treap that the given prefix
+// abbreviates, and true if there is one. A prefix abbreviates the value
+// that equals it, or else the only value that starts with it. If more
+// than one value starts with the prefix and none equals it, ambiguous
+// is true. The values are looked up in byte order, so in other orders
+// every value with the prefix is walked.
+func (t *Treap) ResolvePrefix(prefix string) (value string, found, ambiguous bool) {
+	view := t.byteView(prefix)
+	first, ok := view.ceiling(prefix)
+	if !ok || !strings.HasPrefix(first, prefix) {
+		return "", false, false
+	}
+	if first == prefix {
+		return first, true, false
+	}
+
+	if next, ok := view.successor(first); ok && strings.HasPrefix(next, prefix) {
+		return "", false, true
+	}
+	return first, true, false
+}
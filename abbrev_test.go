@@ -0,0 +1,94 @@
+package treap
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// abbrevValues are the values of the Treaps in the abbreviation tests.
+var abbrevValues = []string{"", "ab", "abc", "abd", "b", "ba12", "ba34"}
+
+func TestTreap_ShortestUniquePrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		want   string
+		wantOk bool
+	}{
+		{name: "empty value", value: "", want: "", wantOk: true},
+		{name: "prefix of another value", value: "ab", want: "ab", wantOk: true},
+		{name: "shares a prefix", value: "abc", want: "abc", wantOk: true},
+		{name: "prefix of other values", value: "b", want: "b", wantOk: true},
+		{name: "differs late", value: "ba12", want: "ba1", wantOk: true},
+		{name: "not in treap", value: "zz", want: "", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trp := NewTreap()
+			for _, v := range abbrevValues {
+				trp.Insert(v)
+			}
+
+			got, ok := trp.ShortestUniquePrefix(tt.value)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOk, ok)
+		})
+	}
+}
+
+func TestTreap_ResolvePrefix(t *testing.T) {
+	tests := []struct {
+		name          string
+		prefix        string
+		want          string
+		wantFound     bool
+		wantAmbiguous bool
+	}{
+		{name: "equal to a value", prefix: "ab", want: "ab", wantFound: true},
+		{name: "unique prefix", prefix: "ba3", want: "ba34", wantFound: true},
+		{name: "ambiguous prefix", prefix: "ba", wantAmbiguous: true},
+		{name: "no values", prefix: "c"},
+		{name: "empty prefix equal to a value", prefix: "", want: "", wantFound: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trp := NewTreap()
+			for _, v := range abbrevValues {
+				trp.Insert(v)
+			}
+
+			got, found, ambiguous := trp.ResolvePrefix(tt.prefix)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantFound, found)
+			assert.Equal(t, tt.wantAmbiguous, ambiguous)
+		})
+	}
+}
+
+func TestTreap_ShortestUniquePrefix_Hashes(t *testing.T) {
+	trp := NewTreap()
+	for i := 0; i < 5000; i++ {
+		sum := sha1.Sum([]byte(fmt.Sprint(i)))
+		trp.Insert(hex.EncodeToString(sum[:]))
+	}
+
+	trp.Iterate(func(value string) bool {
+		prefix, ok := trp.ShortestUniquePrefix(value)
+		assert.True(t, ok)
+		assert.True(t, strings.HasPrefix(value, prefix))
+
+		// the prefix resolves to the value and a shorter one doesn't
+		got, found, _ := trp.ResolvePrefix(prefix)
+		assert.True(t, found)
+		assert.Equal(t, value, got)
+		_, found, ambiguous := trp.ResolvePrefix(prefix[:len(prefix)-1])
+		assert.False(t, found)
+		assert.True(t, ambiguous)
+		return true
+	})
+}
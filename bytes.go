@@ -0,0 +1,158 @@
+package treap
+
+import "bytes"
+
+// BytesTreap is a Treap keyed by byte slices, which are ordered by
+// bytes.Compare. Lookups take a byte slice as is, so they don't
+// allocate, and keys may be stored in buffers owned by the caller
+// rather than copied.
+type BytesTreap struct {
+	root *bytesNode
+}
+
+// bytesNode represents a key and its priority in a BytesTreap.
+type bytesNode struct {
+	key      []byte
+	priority int64
+	left     *bytesNode
+	right    *bytesNode
+}
+
+// NewBytesTreap returns a new BytesTreap.
+func NewBytesTreap() *BytesTreap {
+	return &BytesTreap{}
+}
+
+// Search returns true if the given key is in the BytesTreap.
+// Otherwise, returns false.
+func (t *BytesTreap) Search(key []byte) bool {
+	for n := t.root; n != nil; {
+		c := bytes.Compare(key, n.key)
+		if c == 0 {
+			return true
+		}
+
+		if c < 0 {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+
+	return false
+}
+
+// Iterate calls fn for each key in the BytesTreap in ascending order.
+// The keys are those stored in the BytesTreap, and must not be modified.
+// Iteration stops early if fn returns false.
+func (t *BytesTreap) Iterate(fn func(key []byte) bool) {
+	var stack []*bytesNode
+	for n := t.root; n != nil || len(stack) > 0; n = n.right {
+		for ; n != nil; n = n.left {
+			stack = append(stack, n)
+		}
+
+		n = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !fn(n.key) {
+			return
+		}
+	}
+}
+
+// Insert inserts a copy of the given key into the BytesTreap.
+func (t *BytesTreap) Insert(key []byte) {
+	if t.Search(key) {
+		return
+	}
+
+	t.root = bytesInsert(t.root, append([]byte{}, key...), randomPriority())
+}
+
+// InsertNoCopy inserts the given key into the BytesTreap without copying
+// it, so that the BytesTreap stores the buffer owned by the caller. The
+// key must not be modified while it's in the BytesTreap.
+func (t *BytesTreap) InsertNoCopy(key []byte) {
+	if t.Search(key) {
+		return
+	}
+
+	t.root = bytesInsert(t.root, key, randomPriority())
+}
+
+// Delete deletes the given key from the BytesTreap.
+func (t *BytesTreap) Delete(key []byte) {
+	t.root = bytesDelete(t.root, key)
+}
+
+// bytesInsert inserts a node with the passed key and priority into the
+// tree rooted at the passed node, which doesn't have the key, and
+// returns the new root.
+func bytesInsert(n *bytesNode, key []byte, priority int64) *bytesNode {
+	if n == nil {
+		return &bytesNode{
+			key:      key,
+			priority: priority,
+		}
+	}
+
+	if bytes.Compare(key, n.key) < 0 {
+		n.left = bytesInsert(n.left, key, priority)
+		if n.left.priority > n.priority {
+			pivot := n.left
+			n.left = pivot.right
+			pivot.right = n
+			return pivot
+		}
+	} else {
+		n.right = bytesInsert(n.right, key, priority)
+		if n.right.priority > n.priority {
+			pivot := n.right
+			n.right = pivot.left
+			pivot.left = n
+			return pivot
+		}
+	}
+
+	return n
+}
+
+// bytesDelete deletes the node with the passed key from the tree
+// rooted at the passed node and returns the new root.
+func bytesDelete(n *bytesNode, key []byte) *bytesNode {
+	if n == nil {
+		return nil
+	}
+
+	c := bytes.Compare(key, n.key)
+	switch {
+	case c == 0:
+		return bytesJoin(n.left, n.right)
+	case c < 0:
+		n.left = bytesDelete(n.left, key)
+	default:
+		n.right = bytesDelete(n.right, key)
+	}
+
+	return n
+}
+
+// bytesJoin joins the trees rooted at the passed nodes, where every key
+// of the first is less than every key of the second, and returns the
+// root of the joined tree.
+func bytesJoin(l, r *bytesNode) *bytesNode {
+	if l == nil {
+		return r
+	}
+	if r == nil {
+		return l
+	}
+
+	if l.priority > r.priority {
+		l.right = bytesJoin(l.right, r)
+		return l
+	}
+
+	r.left = bytesJoin(l, r.left)
+	return r
+}
@@ -0,0 +1,144 @@
+package treap
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBytesTreap(t *testing.T) {
+	tests := []struct {
+		name   string
+		insert []string
+		delete []string
+		want   []string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:   "inserts",
+			insert: []string{"b", "a", "c", "a"},
+			want:   []string{"a", "b", "c"},
+		},
+		{
+			name:   "inserts and deletes",
+			insert: []string{"b", "a", "c", "\x00", "\xff"},
+			delete: []string{"a", "d", "\xff"},
+			want:   []string{"\x00", "b", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trp := NewBytesTreap()
+			for _, k := range tt.insert {
+				trp.Insert([]byte(k))
+			}
+			for _, k := range tt.delete {
+				trp.Delete([]byte(k))
+			}
+
+			var got []string
+			trp.Iterate(func(key []byte) bool {
+				got = append(got, string(key))
+				return true
+			})
+			assert.Equal(t, tt.want, got)
+			assert.True(t, hasBytesTreapProperties(trp.root))
+			for _, k := range tt.want {
+				assert.True(t, trp.Search([]byte(k)))
+			}
+			for _, k := range tt.delete {
+				assert.False(t, trp.Search([]byte(k)))
+			}
+		})
+	}
+}
+
+func TestBytesTreap_Random(t *testing.T) {
+	trp := NewBytesTreap()
+	want := make(map[string]bool)
+	for i := 0; i < 5000; i++ {
+		k := fmt.Sprint(rand.Intn(2000))
+		if rand.Intn(3) == 0 {
+			trp.Delete([]byte(k))
+			want[k] = false
+		} else {
+			trp.Insert([]byte(k))
+			want[k] = true
+		}
+	}
+
+	var wantKeys []string
+	for k, ok := range want {
+		if ok {
+			wantKeys = append(wantKeys, k)
+		}
+	}
+	sort.Strings(wantKeys)
+
+	var got []string
+	trp.Iterate(func(key []byte) bool {
+		got = append(got, string(key))
+		return true
+	})
+	assert.Equal(t, wantKeys, got)
+	assert.True(t, hasBytesTreapProperties(trp.root))
+}
+
+func TestBytesTreap_Copies(t *testing.T) {
+	trp := NewBytesTreap()
+
+	// an inserted key is copied, so changing the buffer
+	// doesn't change the key
+	buf := []byte("copied")
+	trp.Insert(buf)
+	buf[0] = 'C'
+	assert.True(t, trp.Search([]byte("copied")))
+
+	// a key inserted without copying is the buffer itself
+	owned := []byte("owned")
+	trp.InsertNoCopy(owned)
+	trp.Iterate(func(key []byte) bool {
+		if bytes.Equal(key, owned) {
+			assert.True(t, &key[0] == &owned[0])
+		}
+		return true
+	})
+}
+
+func TestBytesTreap_Search_Allocs(t *testing.T) {
+	trp := NewBytesTreap()
+	for i := 0; i < 1000; i++ {
+		trp.Insert([]byte(fmt.Sprint(i)))
+	}
+
+	key := []byte("500")
+	allocs := testing.AllocsPerRun(100, func() {
+		trp.Search(key)
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+// hasBytesTreapProperties returns true if the tree rooted at the passed
+// node is ordered by key and is a heap by priority.
+func hasBytesTreapProperties(n *bytesNode) bool {
+	if n == nil {
+		return true
+	}
+
+	if n.left != nil && (bytes.Compare(n.left.key, n.key) >= 0 ||
+		n.left.priority > n.priority) {
+		return false
+	}
+	if n.right != nil && (bytes.Compare(n.right.key, n.key) <= 0 ||
+		n.right.priority > n.priority) {
+		return false
+	}
+
+	return hasBytesTreapProperties(n.left) && hasBytesTreapProperties(n.right)
+}
@@ -0,0 +1,22 @@
+package treap
+
+// Clone returns an independent copy of the Treap in constant time.
+// The copy shares all nodes with the Treap, and either of them copies
+// a shared node only when it mutates the node. The copy starts at the
+// version of the Treap and doesn't retain its past versions, and
+// derives its priorities and orders its values in the same way as
+// the Treap.
+func (t *Treap) Clone() *Treap {
+	t.freeze()
+
+	c := &Treap{
+		root:    t.root,
+		version: t.version,
+		key:     t.key,
+		prf:     newPRF(t.key),
+		order:   t.order,
+	}
+	c.freeze()
+
+	return c
+}
@@ -0,0 +1,46 @@
+package treap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreap_Clone(t *testing.T) {
+	trp := NewTreap()
+	for _, v := range []string{"d", "b", "f", "a", "c", "e", "g"} {
+		trp.Insert(v)
+	}
+
+	clone := trp.Clone()
+	assert.True(t, clone.root == trp.root)
+	assert.Equal(t, trp.Version(), clone.Version())
+
+	trp.Insert("h")
+	trp.Delete("a")
+	clone.Delete("d")
+	clone.Insert("i")
+
+	assert.Equal(t, []string{"b", "c", "d", "e", "f", "g", "h"}, collect(trp))
+	assert.Equal(t, []string{"a", "b", "c", "e", "f", "g", "i"}, collect(clone))
+	assert.True(t, hasTreapProperties(trp.root))
+	assert.True(t, hasTreapProperties(clone.root))
+}
+
+func TestTreap_CloneMixedOps(t *testing.T) {
+	trp := NewTreap()
+	inserted := fillTree(trp, 1000)
+	want := collect(trp)
+
+	// Delete everything from a clone of the treap
+	clone := trp.Clone()
+	for k := range inserted {
+		clone.Delete(k)
+		assert.False(t, clone.Search(k))
+	}
+	assert.Nil(t, clone.root)
+
+	// Assert that the original treap is unchanged
+	assert.Equal(t, want, collect(trp))
+	assert.True(t, hasTreapProperties(trp.root))
+}
@@ -0,0 +1,75 @@
+package treap
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// Equal returns true if the given Treaps hold the same values.
+// The Treaps must be in the same order.
+// The values are compared in ascending order, stopping at the first
+// difference, and Treaps that share their nodes are equal at once.
+func Equal(a, b *Treap) bool {
+	if a.root == b.root {
+		return true
+	}
+
+	return compare(a.root, b.root, a.order) == 0
+}
+
+// Compare compares the values of the given Treaps in ascending order,
+// like words of which the values are the letters. The result is 0 if
+// a holds the same values as b, -1 if a is less than b, and +1 if a is
+// greater than b. A Treap whose values are a prefix of the values of
+// another is the lesser of the two. The values are compared in the order
+// of a, which b must also be in.
+func Compare(a, b *Treap) int {
+	if a.root == b.root {
+		return 0
+	}
+
+	return compare(a.root, b.root, a.order)
+}
+
+// compare compares the values of the trees rooted at the passed nodes,
+// which are in the passed order, in the manner of Compare.
+func compare(a, b *node, order Order) int {
+	ca, cb := newCursor(a, order), newCursor(b, order)
+	for {
+		na, okA := ca.next()
+		nb, okB := cb.next()
+
+		switch {
+		case !okA && !okB:
+			return 0
+		case !okA:
+			return -1
+		case !okB:
+			return 1
+		case na.value == nb.value:
+		case less(order, na.value, nb.value):
+			return -1
+		default:
+			return 1
+		}
+	}
+}
+
+// ContentHash returns a SHA-256 hash of the values of the Treap in
+// ascending order. The hash depends only on the values, and not on the
+// shape of the Treap, so Treaps that hold the same values have the same
+// hash. Each value is hashed as its length, encoded as an unsigned
+// varint, followed by its bytes.
+func (t *Treap) ContentHash() Hash {
+	var size [binary.MaxVarintLen64]byte
+	h := sha256.New()
+	inorder(t.root, func(n *node) bool {
+		h.Write(size[:binary.PutUvarint(size[:], uint64(len(n.value)))])
+		h.Write([]byte(n.value))
+		return true
+	})
+
+	var sum Hash
+	h.Sum(sum[:0])
+	return sum
+}
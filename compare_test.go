@@ -0,0 +1,133 @@
+package treap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name      string
+		a         []string
+		b         []string
+		want      int
+		wantEqual bool
+	}{
+		{
+			name:      "empty treaps",
+			want:      0,
+			wantEqual: true,
+		},
+		{
+			name:      "same values",
+			a:         []string{"a", "b", "c"},
+			b:         []string{"c", "b", "a"},
+			want:      0,
+			wantEqual: true,
+		},
+		{
+			name: "empty is less",
+			b:    []string{"a"},
+			want: -1,
+		},
+		{
+			name: "prefix is less",
+			a:    []string{"a", "b"},
+			b:    []string{"a", "b", "c"},
+			want: -1,
+		},
+		{
+			name: "first difference decides",
+			a:    []string{"a", "c"},
+			b:    []string{"a", "b", "d"},
+			want: 1,
+		},
+		{
+			name: "lesser value is less",
+			a:    []string{"a", "b", "z"},
+			b:    []string{"a", "c"},
+			want: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := NewTreap(), NewTreap()
+			for _, v := range tt.a {
+				a.Insert(v)
+			}
+			for _, v := range tt.b {
+				b.Insert(v)
+			}
+
+			assert.Equal(t, tt.want, Compare(a, b))
+			assert.Equal(t, -tt.want, Compare(b, a))
+			assert.Equal(t, tt.wantEqual, Equal(a, b))
+			assert.Equal(t, tt.wantEqual, Equal(b, a))
+			assert.Equal(t, tt.wantEqual, a.ContentHash() == b.ContentHash())
+		})
+	}
+}
+
+func TestEqual_Clone(t *testing.T) {
+	a := NewTreap()
+	fillTree(a, 100)
+
+	c := a.Clone()
+	assert.True(t, Equal(a, c))
+
+	c.Insert("not in a")
+	assert.False(t, Equal(a, c))
+
+	c.Delete("not in a")
+	assert.True(t, Equal(a, c))
+}
+
+func TestTreap_ContentHash(t *testing.T) {
+	tests := []struct {
+		name   string
+		a      []string
+		b      []string
+		wantEq bool
+	}{
+		{
+			name:   "empty treaps",
+			wantEq: true,
+		},
+		{
+			name: "value boundaries are hashed",
+			a:    []string{"ab", "c"},
+			b:    []string{"a", "bc"},
+		},
+		{
+			name: "empty value is hashed",
+			a:    []string{""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := NewTreap(), NewTreap()
+			for _, v := range tt.a {
+				a.Insert(v)
+			}
+			for _, v := range tt.b {
+				b.Insert(v)
+			}
+
+			assert.Equal(t, tt.wantEq, a.ContentHash() == b.ContentHash())
+		})
+	}
+}
+
+func TestCursor(t *testing.T) {
+	trp := NewTreap()
+	fillTree(trp, 500)
+
+	var values []string
+	c := newCursor(trp.root, nil)
+	for n, ok := c.next(); ok; n, ok = c.next() {
+		values = append(values, n.value)
+	}
+
+	assert.Equal(t, collect(trp), values)
+}
@@ -0,0 +1,142 @@
+package treap
+
+// Diff calls fn for each value that differs between the old and the
+// new Treap in ascending order, with Inserted for a value that was added
+// to the new Treap and Deleted for a value that was removed from it.
+// The diff stops early if fn returns false. The Treaps must be in the
+// same order.
+//
+// Subtrees that the Treaps share, such as after a Clone or between the
+// versions of a versioned Treap, are skipped without being walked, so
+// the diff of Treaps that share most of their nodes takes time in
+// proportion to the number of differences rather than values.
+func Diff(old, new *Treap, fn func(kind ChangeKind, value string) bool) {
+	diff(old.root, new.root, old.order, fn)
+}
+
+// diff calls fn for each value that differs between the trees rooted at
+// the passed nodes, which are in the passed order, in the manner of Diff.
+// Returns the number of nodes visited on both sides, which grows with the
+// number of differences.
+func diff(old, new *node, order Order, fn func(kind ChangeKind, value string) bool) int {
+	a, b := &frontier{}, &frontier{}
+	a.push(old, true)
+	b.push(new, true)
+
+	for !a.empty() && !b.empty() {
+		ta, tb := a.top(), b.top()
+		switch {
+		case ta.whole && tb.whole && ta.n == tb.n:
+			// a shared subtree holds the same values on both sides
+			a.pop()
+			b.pop()
+		case ta.whole || tb.whole:
+			// a subtree is expanded until its least value is at the top,
+			// starting with the higher of the two so that a subtree that
+			// is shared lower down may reach the top on both sides
+			switch {
+			case !tb.whole || (ta.whole && ta.n.priority > tb.n.priority):
+				a.expand()
+			case !ta.whole || tb.n.priority > ta.n.priority:
+				b.expand()
+			default:
+				a.expand()
+				b.expand()
+			}
+		case less(order, ta.n.value, tb.n.value):
+			a.pop()
+			if !fn(Deleted, ta.n.value) {
+				return a.steps + b.steps
+			}
+		case less(order, tb.n.value, ta.n.value):
+			b.pop()
+			if !fn(Inserted, tb.n.value) {
+				return a.steps + b.steps
+			}
+		default:
+			a.pop()
+			b.pop()
+		}
+	}
+
+	// the values left on one side are all greater than those diffed
+	if a.drain(Deleted, fn) {
+		b.drain(Inserted, fn)
+	}
+	return a.steps + b.steps
+}
+
+// frontier holds the values of a tree that are yet to be diffed, as a
+// stack of entries whose values ascend from the top. An entry is either
+// a whole subtree or the value of a single node.
+type frontier struct {
+	stack []frontierEntry
+
+	// steps is the number of nodes pushed onto or drained from
+	// the frontier.
+	steps int
+}
+
+// frontierEntry is an entry of a frontier.
+type frontierEntry struct {
+	n     *node
+	whole bool
+}
+
+// push pushes the passed node, as a whole subtree if whole is true,
+// unless the node is nil.
+func (f *frontier) push(n *node, whole bool) {
+	if n != nil {
+		f.steps++
+		f.stack = append(f.stack, frontierEntry{n: n, whole: whole})
+	}
+}
+
+// empty returns true if the frontier has no values left.
+func (f *frontier) empty() bool {
+	return len(f.stack) == 0
+}
+
+// top returns the entry at the top of the frontier.
+func (f *frontier) top() frontierEntry {
+	return f.stack[len(f.stack)-1]
+}
+
+// pop removes the entry at the top of the frontier.
+func (f *frontier) pop() frontierEntry {
+	e := f.top()
+	f.stack = f.stack[:len(f.stack)-1]
+	return e
+}
+
+// expand replaces the whole subtree at the top of the frontier
+// with its left subtree, its root node and its right subtree.
+func (f *frontier) expand() {
+	e := f.pop()
+	f.push(e.n.right, true)
+	f.push(e.n, false)
+	f.push(e.n.left, true)
+}
+
+// drain calls fn with the passed kind for each value left in the
+// frontier in ascending order, until fn returns false.
+// Returns false if fn returned false.
+func (f *frontier) drain(kind ChangeKind, fn func(kind ChangeKind, value string) bool) bool {
+	for !f.empty() {
+		e := f.pop()
+		if !e.whole {
+			if !fn(kind, e.n.value) {
+				return false
+			}
+			continue
+		}
+
+		if !inorder(e.n, func(n *node) bool {
+			f.steps++
+			return fn(kind, n.value)
+		}) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,231 @@
+package treap
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// diffEvent is a value reported by Diff.
+type diffEvent struct {
+	kind  ChangeKind
+	value string
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		old  []string
+		new  []string
+		want []diffEvent
+	}{
+		{
+			name: "empty treaps",
+		},
+		{
+			name: "all added",
+			new:  []string{"b", "a"},
+			want: []diffEvent{{Inserted, "a"}, {Inserted, "b"}},
+		},
+		{
+			name: "all removed",
+			old:  []string{"b", "a"},
+			want: []diffEvent{{Deleted, "a"}, {Deleted, "b"}},
+		},
+		{
+			name: "same values",
+			old:  []string{"a", "b", "c"},
+			new:  []string{"c", "b", "a"},
+		},
+		{
+			name: "added and removed",
+			old:  []string{"a", "b", "d", "e"},
+			new:  []string{"b", "c", "e", "f"},
+			want: []diffEvent{
+				{Deleted, "a"},
+				{Inserted, "c"},
+				{Deleted, "d"},
+				{Inserted, "f"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old, new := NewTreap(), NewTreap()
+			for _, v := range tt.old {
+				old.Insert(v)
+			}
+			for _, v := range tt.new {
+				new.Insert(v)
+			}
+
+			assert.Equal(t, tt.want, diffEvents(old, new))
+		})
+	}
+}
+
+func TestDiff_Shared(t *testing.T) {
+	tests := []struct {
+		name    string
+		inserts int
+		deletes int
+	}{
+		{
+			name: "unchanged clone",
+		},
+		{
+			name:    "clone with inserts",
+			inserts: 10,
+		},
+		{
+			name:    "clone with deletes",
+			deletes: 10,
+		},
+		{
+			name:    "clone with inserts and deletes",
+			inserts: 50,
+			deletes: 50,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := NewTreap()
+			for i := 0; i < 10000; i++ {
+				old.Insert(fmt.Sprintf("%05d", i*2))
+			}
+
+			new := old.Clone()
+			var want []diffEvent
+			for i := 0; i < tt.inserts; i++ {
+				new.Insert(fmt.Sprintf("%05d", rand.Intn(10000)*2+1))
+			}
+			for i := 0; i < tt.deletes; i++ {
+				new.Delete(fmt.Sprintf("%05d", rand.Intn(10000)*2))
+			}
+
+			// the expected diff compares the values in full
+			olds, news := collect(old), collect(new)
+			for len(olds) > 0 || len(news) > 0 {
+				switch {
+				case len(news) == 0 || (len(olds) > 0 && olds[0] < news[0]):
+					want = append(want, diffEvent{Deleted, olds[0]})
+					olds = olds[1:]
+				case len(olds) == 0 || news[0] < olds[0]:
+					want = append(want, diffEvent{Inserted, news[0]})
+					news = news[1:]
+				default:
+					olds, news = olds[1:], news[1:]
+				}
+			}
+
+			assert.Equal(t, want, diffEvents(old, new))
+		})
+	}
+}
+
+func TestDiff_Work(t *testing.T) {
+	newTreap := func(n int) *Treap {
+		trp := NewKeyedTreap([]byte("key"))
+		for i := 0; i < n; i++ {
+			trp.Insert(fmt.Sprintf("%07d", i*2))
+		}
+		return trp
+	}
+	small, large := newTreap(20000), newTreap(200000)
+
+	// steps returns the number of nodes visited to diff the passed Treap
+	// against a Clone of it with the passed number of edits, half of
+	// them inserts and half of them deletes, spread over its values
+	steps := func(old *Treap, n, edits int) int {
+		new := old.Clone()
+		for i := 0; i < edits/2; i++ {
+			new.Insert(fmt.Sprintf("%07d", (i*2*n/edits)*2+1))
+			new.Delete(fmt.Sprintf("%07d", (i*2*n/edits+n/edits)*2))
+		}
+
+		changes := 0
+		visited := diff(old.root, new.root, nil, func(ChangeKind, string) bool {
+			changes++
+			return true
+		})
+		assert.Equal(t, edits, changes)
+		return visited
+	}
+
+	tests := []struct {
+		name  string
+		edits int
+	}{
+		{
+			name:  "unchanged",
+			edits: 0,
+		},
+		{
+			name:  "few edits",
+			edits: 2,
+		},
+		{
+			name:  "some edits",
+			edits: 20,
+		},
+		{
+			name:  "many edits",
+			edits: 200,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := steps(small, 20000, tt.edits), steps(large, 200000, tt.edits)
+
+			// Assert that ten times the values takes far less than ten
+			// times the work, which grows with the number of edits times
+			// the logarithm of the number of values
+			assert.True(t, b <= 2*a+2, "%d steps, then %d steps", a, b)
+			assert.True(t, b <= 2+16*tt.edits*bits.Len(200000), "%d steps", b)
+		})
+	}
+}
+
+func TestDiff_Versions(t *testing.T) {
+	trp := NewTreap()
+	trp.EnableVersioning()
+	for i := 0; i < 1000; i++ {
+		trp.Insert(fmt.Sprintf("%04d", i))
+	}
+	v := trp.Version()
+	trp.Delete("0500")
+	trp.Insert("0500x")
+
+	root, err := trp.rootAt(v)
+	assert.NoError(t, err)
+	old := &Treap{root: root}
+	assert.Equal(t, []diffEvent{{Deleted, "0500"}, {Inserted, "0500x"}},
+		diffEvents(old, trp))
+}
+
+func TestDiff_StopEarly(t *testing.T) {
+	old, new := NewTreap(), NewTreap()
+	for _, v := range []string{"a", "b", "c"} {
+		new.Insert(v)
+	}
+
+	var values []string
+	Diff(old, new, func(kind ChangeKind, value string) bool {
+		values = append(values, value)
+		return len(values) < 2
+	})
+	assert.Equal(t, []string{"a", "b"}, values)
+}
+
+// diffEvents returns the values reported by Diff for the passed Treaps.
+func diffEvents(old, new *Treap) []diffEvent {
+	var events []diffEvent
+	Diff(old, new, func(kind ChangeKind, value string) bool {
+		events = append(events, diffEvent{kind, value})
+		return true
+	})
+	return events
+}
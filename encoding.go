@@ -0,0 +1,108 @@
+package treap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MarshalJSON returns the values of the Treap as
+// a JSON array of strings in ascending order.
+func (t *Treap) MarshalJSON() ([]byte, error) {
+	values := make([]string, 0)
+	t.Iterate(func(value string) bool {
+		values = append(values, value)
+		return true
+	})
+
+	return json.Marshal(values)
+}
+
+// UnmarshalJSON replaces the contents of the Treap with the values
+// of the given JSON array of strings. Since the replacement can't be
+// undone, the history of the Treap is cleared.
+func (t *Treap) UnmarshalJSON(data []byte) error {
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	t.replaceValues(values)
+	return nil
+}
+
+// MarshalText returns the values of the Treap in ascending order,
+// one per line, each quoted as a Go string literal.
+func (t *Treap) MarshalText() ([]byte, error) {
+	var b bytes.Buffer
+	t.Iterate(func(value string) bool {
+		b.WriteString(strconv.Quote(value))
+		b.WriteByte('\n')
+		return true
+	})
+
+	return b.Bytes(), nil
+}
+
+// UnmarshalText replaces the contents of the Treap with the values in
+// the given text, one per line, each quoted as a Go string literal.
+// Blank lines are ignored. Since the replacement can't be undone,
+// the history of the Treap is cleared.
+func (t *Treap) UnmarshalText(text []byte) error {
+	var values []string
+	for i, line := range strings.Split(string(text), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		value, err := strconv.Unquote(line)
+		if err != nil {
+			return fmt.Errorf("treap: line %d: %v", i+1, err)
+		}
+		values = append(values, value)
+	}
+
+	t.replaceValues(values)
+	return nil
+}
+
+// MarshalBinary returns a binary snapshot of the Treap.
+func (t *Treap) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := t.WriteTo(&b); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// UnmarshalBinary replaces the contents of the Treap with
+// the given binary snapshot.
+func (t *Treap) UnmarshalBinary(data []byte) error {
+	_, err := t.ReadFrom(bytes.NewReader(data))
+	return err
+}
+
+// GobEncode returns a binary snapshot of the Treap.
+func (t *Treap) GobEncode() ([]byte, error) {
+	return t.MarshalBinary()
+}
+
+// GobDecode replaces the contents of the Treap with
+// the given binary snapshot.
+func (t *Treap) GobDecode(data []byte) error {
+	return t.UnmarshalBinary(data)
+}
+
+// replaceValues replaces the contents of the Treap with the passed values.
+func (t *Treap) replaceValues(values []string) {
+	var root *node
+	for _, value := range values {
+		root = insert(root, value, t.priority(value), t.owner, t.order)
+	}
+
+	t.replace(root)
+}
@@ -0,0 +1,111 @@
+package treap
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreap_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   string
+	}{
+		{
+			name:   "empty treap",
+			values: nil,
+			want:   `{"Set":[]}`,
+		},
+		{
+			name:   "populated treap",
+			values: []string{"c", "a", "b"},
+			want:   `{"Set":["a","b","c"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			type config struct {
+				Set *Treap
+			}
+
+			trp := NewTreap()
+			for _, v := range tt.values {
+				trp.Insert(v)
+			}
+
+			data, err := json.Marshal(config{Set: trp})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+
+			var decoded config
+			assert.NoError(t, json.Unmarshal(data, &decoded))
+			assert.Equal(t, collect(trp), collect(decoded.Set))
+			assert.True(t, hasTreapProperties(decoded.Set.root))
+		})
+	}
+}
+
+func TestTreap_UnmarshalJSON(t *testing.T) {
+	trp := NewTreap()
+	trp.Insert("existing")
+
+	assert.NoError(t, trp.UnmarshalJSON([]byte(`["b","a","b"]`)))
+	assert.Equal(t, []string{"a", "b"}, collect(trp))
+
+	assert.Error(t, trp.UnmarshalJSON([]byte(`{"a":1}`)))
+	assert.Equal(t, []string{"a", "b"}, collect(trp))
+}
+
+func TestTreap_MarshalText(t *testing.T) {
+	trp := NewTreap()
+	for _, v := range []string{"b c", "a\nb", "", `"q"`} {
+		trp.Insert(v)
+	}
+
+	text, err := trp.MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, "\"\"\n\"\\\"q\\\"\"\n\"a\\nb\"\n\"b c\"\n", string(text))
+
+	decoded := NewTreap()
+	assert.NoError(t, decoded.UnmarshalText(text))
+	assert.Equal(t, collect(trp), collect(decoded))
+
+	assert.Error(t, decoded.UnmarshalText([]byte("\"a\"\nb\n")))
+}
+
+func TestTreap_MarshalBinary(t *testing.T) {
+	trp := NewTreap()
+	fillTree(trp, 1000)
+
+	data, err := trp.MarshalBinary()
+	assert.NoError(t, err)
+
+	decoded := NewTreap()
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, trp.root, decoded.root)
+}
+
+func TestTreap_GobEncode(t *testing.T) {
+	type payload struct {
+		Name string
+		Set  *Treap
+	}
+
+	trp := NewTreap()
+	fillTree(trp, 1000)
+
+	var buf bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&buf).Encode(payload{
+		Name: "set",
+		Set:  trp,
+	}))
+
+	var decoded payload
+	assert.NoError(t, gob.NewDecoder(&buf).Decode(&decoded))
+	assert.Equal(t, "set", decoded.Name)
+	assert.Equal(t, trp.root, decoded.Set.root)
+}
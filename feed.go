@@ -0,0 +1,321 @@
+package treap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+)
+
+// ChangeKind is the kind of a Change.
+type ChangeKind uint8
+
+const (
+	// Inserted is the kind of a Change that inserted a value.
+	Inserted ChangeKind = iota + 1
+
+	// Deleted is the kind of a Change that deleted a value.
+	Deleted
+)
+
+// String returns the name of the ChangeKind.
+func (k ChangeKind) String() string {
+	switch k {
+	case Inserted:
+		return "Inserted"
+	case Deleted:
+		return "Deleted"
+	default:
+		return fmt.Sprintf("ChangeKind(%d)", uint8(k))
+	}
+}
+
+// Change is a change to the contents of a Treap.
+type Change struct {
+	// Seq is the sequence number of the change. The changes published
+	// to the subscriptions of a Treap are numbered consecutively,
+	// starting from 1.
+	Seq   uint64
+	Kind  ChangeKind
+	Value string
+}
+
+// ErrFeedGap is returned when applying a Change whose sequence number
+// doesn't follow the sequence number of the last Change applied.
+var ErrFeedGap = errors.New("treap: gap in change feed")
+
+// Subscription is an ordered stream of the changes to a Treap.
+// Changes are queued without bound until they're read with Next.
+// The methods of a Subscription may be called concurrently with
+// mutations of the Treap.
+type Subscription struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []Change
+	closed bool
+}
+
+// Subscribe returns a Subscription to the changes made to the Treap
+// from then on. Each Insert or Delete that changes the contents of
+// the Treap is a Change. Replacing the contents of the Treap, such as
+// with ReadFrom, is the Changes that turn the old contents into the new.
+func (t *Treap) Subscribe() *Subscription {
+	s := &Subscription{}
+	s.cond = sync.NewCond(&s.mu)
+	t.subscriptions = append(t.subscriptions, s)
+	return s
+}
+
+// Next returns the next Change, waiting for one if none is queued.
+// Returns false once the Subscription is closed and its queued
+// changes have been read.
+func (s *Subscription) Next() (Change, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.queue) == 0 && !s.closed {
+		s.cond.Wait()
+	}
+	if len(s.queue) == 0 {
+		return Change{}, false
+	}
+
+	c := s.queue[0]
+	s.queue = s.queue[1:]
+	return c, true
+}
+
+// Close ends the Subscription, so that no more changes are queued.
+// The changes already queued can still be read with Next.
+func (s *Subscription) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	s.cond.Broadcast()
+}
+
+// WriteTo writes each Change of the Subscription to the given writer in
+// the encoding of FeedWriter, until the Subscription is closed or a write
+// fails. Returns the number of bytes written.
+func (s *Subscription) WriteTo(w io.Writer) (int64, error) {
+	fw := NewFeedWriter(w)
+	for c, ok := s.Next(); ok; c, ok = s.Next() {
+		if err := fw.Write(c); err != nil {
+			return fw.n, err
+		}
+	}
+
+	return fw.n, nil
+}
+
+// publish queues the passed Change unless the Subscription is closed.
+// Returns false if the Subscription is closed.
+func (s *Subscription) publish(c Change) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	s.queue = append(s.queue, c)
+	s.cond.Signal()
+	return true
+}
+
+// publish numbers the passed change and queues it on each open
+// Subscription to the Treap, dropping the closed ones.
+func (t *Treap) publish(kind ChangeKind, value string) {
+	t.seq++
+	c := Change{
+		Seq:   t.seq,
+		Kind:  kind,
+		Value: value,
+	}
+
+	open := t.subscriptions[:0]
+	for _, s := range t.subscriptions {
+		if s.publish(c) {
+			open = append(open, s)
+		}
+	}
+	for i := len(open); i < len(t.subscriptions); i++ {
+		t.subscriptions[i] = nil
+	}
+	t.subscriptions = open
+}
+
+// publishReplace publishes the changes that turn the tree rooted at
+// the first passed node into the tree rooted at the second.
+func (t *Treap) publishReplace(old, new *node) {
+	var olds, news []string
+	inorder(old, func(n *node) bool {
+		olds = append(olds, n.value)
+		return true
+	})
+	inorder(new, func(n *node) bool {
+		news = append(news, n.value)
+		return true
+	})
+
+	for len(olds) > 0 || len(news) > 0 {
+		switch {
+		case len(news) == 0 || (len(olds) > 0 && less(t.order, olds[0], news[0])):
+			t.publish(Deleted, olds[0])
+			olds = olds[1:]
+		case len(olds) == 0 || less(t.order, news[0], olds[0]):
+			t.publish(Inserted, news[0])
+			news = news[1:]
+		default:
+			olds, news = olds[1:], news[1:]
+		}
+	}
+}
+
+// FeedWriter encodes changes to a writer. Each Change is encoded as its
+// sequence number, its kind, the length of its value and its value, with
+// the integers encoded as unsigned varints.
+type FeedWriter struct {
+	w   io.Writer
+	n   int64
+	buf []byte
+}
+
+// NewFeedWriter returns a FeedWriter that writes to the given writer.
+func NewFeedWriter(w io.Writer) *FeedWriter {
+	return &FeedWriter{
+		w: w,
+	}
+}
+
+// Write encodes the given Change to the writer in a single write.
+func (fw *FeedWriter) Write(c Change) error {
+	var scratch [binary.MaxVarintLen64]byte
+	fw.buf = fw.buf[:0]
+	fw.buf = append(fw.buf, scratch[:binary.PutUvarint(scratch[:], c.Seq)]...)
+	fw.buf = append(fw.buf, byte(c.Kind))
+	fw.buf = append(fw.buf,
+		scratch[:binary.PutUvarint(scratch[:], uint64(len(c.Value)))]...)
+	fw.buf = append(fw.buf, c.Value...)
+
+	n, err := fw.w.Write(fw.buf)
+	fw.n += int64(n)
+	return err
+}
+
+// FeedReader decodes changes written by a FeedWriter from a reader.
+type FeedReader struct {
+	r *bufio.Reader
+}
+
+// NewFeedReader returns a FeedReader that reads from the given reader.
+func NewFeedReader(r io.Reader) *FeedReader {
+	return &FeedReader{
+		r: bufio.NewReader(r),
+	}
+}
+
+// Read decodes the next Change from the reader.
+// Returns io.EOF if the reader ends between changes.
+func (fr *FeedReader) Read() (Change, error) {
+	seq, err := binary.ReadUvarint(fr.r)
+	if err != nil {
+		return Change{}, err
+	}
+
+	kind, err := fr.r.ReadByte()
+	if err != nil {
+		return Change{}, unexpectedEOF(err)
+	}
+	if ChangeKind(kind) != Inserted && ChangeKind(kind) != Deleted {
+		return Change{}, fmt.Errorf("treap: invalid change kind %d", kind)
+	}
+
+	size, err := binary.ReadUvarint(fr.r)
+	if err != nil {
+		return Change{}, unexpectedEOF(err)
+	}
+	if size > math.MaxInt64 {
+		return Change{}, fmt.Errorf("treap: invalid change size %d", size)
+	}
+
+	var value strings.Builder
+	if _, err := io.CopyN(&value, fr.r, int64(size)); err != nil {
+		return Change{}, unexpectedEOF(err)
+	}
+
+	return Change{
+		Seq:   seq,
+		Kind:  ChangeKind(kind),
+		Value: value.String(),
+	}, nil
+}
+
+// Follower applies a feed of changes to a Treap, so that it follows the
+// contents of the Treap that the changes were made to.
+type Follower struct {
+	t   *Treap
+	seq uint64
+}
+
+// NewFollower returns a Follower that applies changes to the given Treap.
+// The first Change applied may have any sequence number, so a Follower
+// can start from a snapshot taken when its Subscription was made.
+func NewFollower(t *Treap) *Follower {
+	return &Follower{
+		t: t,
+	}
+}
+
+// Seq returns the sequence number of the last Change applied.
+func (f *Follower) Seq() uint64 {
+	return f.seq
+}
+
+// Apply applies the given Change to the Treap. A Change that was
+// already applied is ignored.
+// Returns ErrFeedGap if changes were missed since the last Change applied.
+func (f *Follower) Apply(c Change) error {
+	if f.seq != 0 && c.Seq <= f.seq {
+		return nil
+	}
+	if f.seq != 0 && c.Seq != f.seq+1 {
+		return fmt.Errorf("%w: expected change %d, got %d",
+			ErrFeedGap, f.seq+1, c.Seq)
+	}
+
+	switch c.Kind {
+	case Inserted:
+		f.t.Insert(c.Value)
+	case Deleted:
+		f.t.Delete(c.Value)
+	default:
+		return fmt.Errorf("treap: invalid change kind %d", uint8(c.Kind))
+	}
+
+	f.seq = c.Seq
+	return nil
+}
+
+// Follow applies the changes read from the given FeedReader until
+// the reader ends. Returns nil if the reader ends between changes.
+func (f *Follower) Follow(fr *FeedReader) error {
+	for {
+		c, err := fr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := f.Apply(c); err != nil {
+			return err
+		}
+	}
+}
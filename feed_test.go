@@ -0,0 +1,166 @@
+package treap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreap_Subscribe(t *testing.T) {
+	trp := NewTreap()
+	trp.Insert("a")
+
+	sub := trp.Subscribe()
+	trp.Insert("b")
+	trp.Insert("b")
+	trp.Delete("a")
+	trp.Delete("z")
+
+	want := []Change{
+		{Seq: 2, Kind: Inserted, Value: "b"},
+		{Seq: 3, Kind: Deleted, Value: "a"},
+	}
+	for _, w := range want {
+		c, ok := sub.Next()
+		assert.True(t, ok)
+		assert.Equal(t, w, c)
+	}
+
+	// Assert that replacing the contents publishes the difference
+	assert.NoError(t, trp.UnmarshalJSON([]byte(`["b","c"]`)))
+	c, ok := sub.Next()
+	assert.True(t, ok)
+	assert.Equal(t, Change{Seq: 4, Kind: Inserted, Value: "c"}, c)
+
+	// Assert that closing keeps the queued changes but queues no more
+	trp.Insert("d")
+	sub.Close()
+	trp.Insert("e")
+	c, ok = sub.Next()
+	assert.True(t, ok)
+	assert.Equal(t, Change{Seq: 5, Kind: Inserted, Value: "d"}, c)
+	_, ok = sub.Next()
+	assert.False(t, ok)
+	assert.Empty(t, trp.subscriptions)
+}
+
+func TestSubscription_Close(t *testing.T) {
+	trp := NewTreap()
+	sub := trp.Subscribe()
+
+	done := make(chan bool)
+	go func() {
+		_, ok := sub.Next()
+		done <- ok
+	}()
+
+	sub.Close()
+	assert.False(t, <-done)
+}
+
+func TestFollower_Follow(t *testing.T) {
+	leader := NewTreap()
+	fillTree(leader, 100)
+
+	// Start the follower from a snapshot taken along with the subscription
+	sub := leader.Subscribe()
+	var snapshot bytes.Buffer
+	_, err := leader.WriteTo(&snapshot)
+	assert.NoError(t, err)
+
+	follower := NewTreap()
+	_, err = follower.ReadFrom(&snapshot)
+	assert.NoError(t, err)
+
+	r, w := io.Pipe()
+	go func() {
+		sub.WriteTo(w)
+		w.Close()
+	}()
+
+	inserted := fillTree(leader, 500)
+	for k := range inserted {
+		if len(k)%2 == 0 {
+			leader.Delete(k)
+		}
+	}
+	sub.Close()
+
+	f := NewFollower(follower)
+	assert.NoError(t, f.Follow(NewFeedReader(r)))
+	assert.Equal(t, leader.seq, f.Seq())
+	assert.Equal(t, collect(leader), collect(follower))
+}
+
+func TestFollower_Apply(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes []Change
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "consecutive changes",
+			changes: []Change{
+				{Seq: 5, Kind: Inserted, Value: "a"},
+				{Seq: 6, Kind: Inserted, Value: "b"},
+				{Seq: 7, Kind: Deleted, Value: "a"},
+			},
+			want: []string{"b"},
+		},
+		{
+			name: "duplicate change",
+			changes: []Change{
+				{Seq: 1, Kind: Inserted, Value: "a"},
+				{Seq: 2, Kind: Deleted, Value: "a"},
+				{Seq: 2, Kind: Deleted, Value: "a"},
+				{Seq: 1, Kind: Inserted, Value: "a"},
+			},
+			want: nil,
+		},
+		{
+			name: "gap in changes",
+			changes: []Change{
+				{Seq: 1, Kind: Inserted, Value: "a"},
+				{Seq: 3, Kind: Inserted, Value: "b"},
+			},
+			want:    []string{"a"},
+			wantErr: ErrFeedGap,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trp := NewTreap()
+			f := NewFollower(trp)
+
+			var err error
+			for _, c := range tt.changes {
+				if err = f.Apply(c); err != nil {
+					break
+				}
+			}
+			assert.True(t, errors.Is(err, tt.wantErr))
+			assert.Equal(t, tt.want, collect(trp))
+		})
+	}
+}
+
+func TestFeedReader_Read(t *testing.T) {
+	var buf bytes.Buffer
+	fw := NewFeedWriter(&buf)
+	assert.NoError(t, fw.Write(Change{Seq: 300, Kind: Deleted, Value: "abc"}))
+
+	fr := NewFeedReader(bytes.NewReader(buf.Bytes()))
+	c, err := fr.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, Change{Seq: 300, Kind: Deleted, Value: "abc"}, c)
+	_, err = fr.Read()
+	assert.Equal(t, io.EOF, err)
+
+	fr = NewFeedReader(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	_, err = fr.Read()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
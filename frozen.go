@@ -0,0 +1,421 @@
+package treap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// The frozen format of a Treap is a single file that can be searched
+// in place, laid out as follows with all integers little endian:
+//
+//	header   frozenHeaderSize bytes:
+//	         magic "TRPF", version (1 byte), 3 reserved bytes,
+//	         count (8 bytes), offset of the root record (8 bytes,
+//	         zero if there are no values), size of the file (8 bytes)
+//	records  count records of frozenRecordSize bytes in level order:
+//	         offset of the value (8 bytes), length of the value
+//	         (4 bytes), 4 reserved bytes, offsets of the left and
+//	         right child records (8 bytes each, zero if absent)
+//	values   the bytes of the values, in level order
+//
+// A child record always follows its parent in the file, which keeps
+// lookups in a corrupted file from looping.
+const (
+	frozenMagic      = "TRPF"
+	frozenVersion    = 1
+	frozenHeaderSize = 32
+	frozenRecordSize = 32
+)
+
+// ErrInvalidFrozen is returned when opening or verifying a file
+// that isn't a valid frozen Treap.
+var ErrInvalidFrozen = errors.New("treap: invalid frozen file")
+
+// WriteFrozen writes the Treap to the given writer in the frozen format,
+// which keeps the shape of the Treap and can be opened with OpenFrozen.
+// A Frozen is searched in byte order, so a Treap in another order is
+// written with the shape that its values take in byte order.
+// Returns the number of bytes written.
+func (t *Treap) WriteFrozen(w io.Writer) (int64, error) {
+	root := t.root
+	if t.order != nil {
+		root = nil
+		inorder(t.root, func(n *node) bool {
+			root = insert(root, n.value, n.priority, 0, nil)
+			return true
+		})
+	}
+
+	count, size := uint64(0), uint64(0)
+	inorder(root, func(n *node) bool {
+		count++
+		size += uint64(len(n.value))
+		return true
+	})
+
+	valuesOffset := frozenHeaderSize + count*frozenRecordSize
+	bw := bufio.NewWriter(w)
+	var written int64
+	write := func(b []byte) error {
+		n, err := bw.Write(b)
+		written += int64(n)
+		return err
+	}
+
+	header := make([]byte, frozenHeaderSize)
+	copy(header, frozenMagic)
+	header[len(frozenMagic)] = frozenVersion
+	binary.LittleEndian.PutUint64(header[8:16], count)
+	if count > 0 {
+		binary.LittleEndian.PutUint64(header[16:24], frozenHeaderSize)
+	}
+	binary.LittleEndian.PutUint64(header[24:32], valuesOffset+size)
+	if err := write(header); err != nil {
+		return written, err
+	}
+
+	// records are numbered in level order as their nodes are queued
+	record := make([]byte, frozenRecordSize)
+	offset := func(i uint64) uint64 {
+		return frozenHeaderSize + i*frozenRecordSize
+	}
+	queued := uint64(1)
+	valueOffset := valuesOffset
+	err := levelorder(root, func(n *node) error {
+		for i := range record {
+			record[i] = 0
+		}
+
+		binary.LittleEndian.PutUint64(record[0:8], valueOffset)
+		binary.LittleEndian.PutUint32(record[8:12], uint32(len(n.value)))
+		valueOffset += uint64(len(n.value))
+		if n.left != nil {
+			binary.LittleEndian.PutUint64(record[16:24], offset(queued))
+			queued++
+		}
+		if n.right != nil {
+			binary.LittleEndian.PutUint64(record[24:32], offset(queued))
+			queued++
+		}
+
+		return write(record)
+	})
+	if err != nil {
+		return written, err
+	}
+
+	err = levelorder(root, func(n *node) error {
+		return write([]byte(n.value))
+	})
+	if err != nil {
+		return written, err
+	}
+
+	return written, bw.Flush()
+}
+
+// Frozen is a read-only Treap that is searched in place in a file
+// written by WriteFrozen, without loading its values into memory.
+// A Frozen is safe for concurrent use until it's closed.
+type Frozen struct {
+	data  []byte
+	count uint64
+	root  uint64
+	close func() error
+}
+
+// OpenFrozen opens the frozen Treap in the named file. Where supported,
+// the file is memory-mapped rather than read into memory.
+// Lookups in a corrupted file return incomplete results but never read
+// outside of the file. Use Verify to check the whole file.
+func OpenFrozen(path string) (*Frozen, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < frozenHeaderSize {
+		return nil, fmt.Errorf("%w: file too small", ErrInvalidFrozen)
+	}
+
+	data, unmap, err := mapFile(f, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+
+	fz := &Frozen{
+		data:  data,
+		close: unmap,
+	}
+	if err := fz.readHeader(); err != nil {
+		unmap()
+		return nil, err
+	}
+
+	return fz, nil
+}
+
+// Close releases the file of the Frozen. The Frozen can't be used
+// after it's closed.
+func (f *Frozen) Close() error {
+	f.data = nil
+	return f.close()
+}
+
+// Len returns the number of values in the Frozen.
+func (f *Frozen) Len() int {
+	return int(f.count)
+}
+
+// Search returns true if the given value is in the Frozen.
+// Otherwise, returns false.
+func (f *Frozen) Search(value string) bool {
+	for off := f.root; off != 0; {
+		v := f.value(off)
+		if value == string(v) {
+			return true
+		}
+
+		if value < string(v) {
+			off = f.left(off)
+		} else {
+			off = f.right(off)
+		}
+	}
+
+	return false
+}
+
+// Floor returns the greatest value in the Frozen that is less than or
+// equal to the given value, and true if there is such a value.
+func (f *Frozen) Floor(value string) (string, bool) {
+	var floor []byte
+	found := false
+	for off := f.root; off != 0; {
+		v := f.value(off)
+		if string(v) == value {
+			return value, true
+		}
+
+		if string(v) < value {
+			floor, found = v, true
+			off = f.right(off)
+		} else {
+			off = f.left(off)
+		}
+	}
+
+	return string(floor), found
+}
+
+// Ceiling returns the least value in the Frozen that is greater than or
+// equal to the given value, and true if there is such a value.
+func (f *Frozen) Ceiling(value string) (string, bool) {
+	var ceiling []byte
+	found := false
+	for off := f.root; off != 0; {
+		v := f.value(off)
+		if string(v) == value {
+			return value, true
+		}
+
+		if string(v) > value {
+			ceiling, found = v, true
+			off = f.left(off)
+		} else {
+			off = f.right(off)
+		}
+	}
+
+	return string(ceiling), found
+}
+
+// Iterate calls fn for each value in the Frozen in ascending order.
+// Iteration stops early if fn returns false.
+func (f *Frozen) Iterate(fn func(value string) bool) {
+	f.ascend(f.root, func(v []byte) bool {
+		return fn(string(v))
+	})
+}
+
+// IterateFrom calls fn for each value in the Frozen that is greater
+// than or equal to the given value in ascending order.
+// Iteration stops early if fn returns false.
+func (f *Frozen) IterateFrom(low string, fn func(value string) bool) {
+	f.ascendFrom(low, func(v []byte) bool {
+		return fn(string(v))
+	})
+}
+
+// Range calls fn for each value in the Frozen that is greater than or
+// equal to low and less than high in ascending order.
+// Iteration stops early if fn returns false.
+func (f *Frozen) Range(low, high string, fn func(value string) bool) {
+	f.ascendFrom(low, func(v []byte) bool {
+		return string(v) < high && fn(string(v))
+	})
+}
+
+// Verify checks that all records of the Frozen are reachable and that
+// its values are in ascending order. It reads the whole file.
+func (f *Frozen) Verify() error {
+	var count uint64
+	var prev []byte
+	var err error
+	f.ascend(f.root, func(v []byte) bool {
+		if count > 0 && string(v) <= string(prev) {
+			err = fmt.Errorf("%w: values out of order", ErrInvalidFrozen)
+			return false
+		}
+
+		prev = v
+		count++
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	// references outside of the file are treated as absent,
+	// so they surface as missing records
+	if count != f.count {
+		return fmt.Errorf("%w: found %d of %d records",
+			ErrInvalidFrozen, count, f.count)
+	}
+
+	return nil
+}
+
+// readHeader reads and checks the header of the Frozen.
+func (f *Frozen) readHeader() error {
+	header := f.data[:frozenHeaderSize]
+	if string(header[:len(frozenMagic)]) != frozenMagic {
+		return fmt.Errorf("%w: bad magic", ErrInvalidFrozen)
+	}
+	if version := header[len(frozenMagic)]; version != frozenVersion {
+		return fmt.Errorf("%w: unsupported version %d",
+			ErrInvalidFrozen, version)
+	}
+
+	f.count = binary.LittleEndian.Uint64(header[8:16])
+	f.root = binary.LittleEndian.Uint64(header[16:24])
+	size := binary.LittleEndian.Uint64(header[24:32])
+	if size != uint64(len(f.data)) {
+		return fmt.Errorf("%w: file is %d bytes, expected %d",
+			ErrInvalidFrozen, len(f.data), size)
+	}
+	if f.count > (size-frozenHeaderSize)/frozenRecordSize {
+		return fmt.Errorf("%w: too many records", ErrInvalidFrozen)
+	}
+	if (f.count == 0) != (f.root == 0) || !f.isRecord(f.root, 0) {
+		return fmt.Errorf("%w: bad root record", ErrInvalidFrozen)
+	}
+
+	return nil
+}
+
+// value returns the bytes of the value of the record at the passed offset.
+// Returns nil if the value isn't within the values of the file.
+func (f *Frozen) value(off uint64) []byte {
+	record := f.data[off : off+frozenRecordSize]
+	start := binary.LittleEndian.Uint64(record[0:8])
+	end := start + uint64(binary.LittleEndian.Uint32(record[8:12]))
+	if start < f.valuesOffset() || end < start || end > uint64(len(f.data)) {
+		return nil
+	}
+
+	return f.data[start:end]
+}
+
+// left returns the offset of the left child of the record at the
+// passed offset, or zero if it has none.
+func (f *Frozen) left(off uint64) uint64 {
+	return f.child(off, 16)
+}
+
+// right returns the offset of the right child of the record at the
+// passed offset, or zero if it has none.
+func (f *Frozen) right(off uint64) uint64 {
+	return f.child(off, 24)
+}
+
+// child returns the offset of the child of the record at the passed
+// offset that is stored at the passed position within the record.
+// Returns zero if it's absent or isn't a valid child record.
+func (f *Frozen) child(off uint64, pos uint64) uint64 {
+	child := binary.LittleEndian.Uint64(f.data[off+pos : off+pos+8])
+	if !f.isRecord(child, off) {
+		return 0
+	}
+	return child
+}
+
+// isRecord returns true if the passed offset is zero or the offset of
+// a record that follows the record at the passed parent offset.
+func (f *Frozen) isRecord(off, parent uint64) bool {
+	return off == 0 ||
+		(off > parent && off >= frozenHeaderSize &&
+			off < f.valuesOffset() &&
+			(off-frozenHeaderSize)%frozenRecordSize == 0)
+}
+
+// valuesOffset returns the offset of the values in the file.
+func (f *Frozen) valuesOffset() uint64 {
+	return frozenHeaderSize + f.count*frozenRecordSize
+}
+
+// ascend calls fn for each value in the subtree rooted at the record at
+// the passed offset in ascending order until fn returns false.
+func (f *Frozen) ascend(off uint64, fn func(v []byte) bool) {
+	var stack []uint64
+	for off != 0 || len(stack) > 0 {
+		for off != 0 {
+			stack = append(stack, off)
+			off = f.left(off)
+		}
+
+		off = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !fn(f.value(off)) {
+			return
+		}
+		off = f.right(off)
+	}
+}
+
+// ascendFrom calls fn for each value that is greater than or equal to
+// the passed value in ascending order until fn returns false.
+func (f *Frozen) ascendFrom(low string, fn func(v []byte) bool) {
+	// stack holds the records with values greater than or equal to low
+	// whose values and right subtrees are yet to be visited
+	var stack []uint64
+	for off := f.root; off != 0; {
+		if string(f.value(off)) >= low {
+			stack = append(stack, off)
+			off = f.left(off)
+		} else {
+			off = f.right(off)
+		}
+	}
+
+	for len(stack) > 0 {
+		off := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !fn(f.value(off)) {
+			return
+		}
+
+		for off = f.right(off); off != 0; off = f.left(off) {
+			stack = append(stack, off)
+		}
+	}
+}
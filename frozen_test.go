@@ -0,0 +1,226 @@
+package treap
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenFrozen(t *testing.T) {
+	trp := NewTreap()
+	fillTree(trp, 2000)
+	path := writeFrozen(t, trp)
+	defer os.Remove(path)
+
+	f, err := OpenFrozen(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	want := collect(trp)
+	assert.Equal(t, len(want), f.Len())
+	assert.NoError(t, f.Verify())
+
+	var got []string
+	f.Iterate(func(value string) bool {
+		got = append(got, value)
+		return true
+	})
+	assert.Equal(t, want, got)
+
+	for _, v := range want {
+		assert.True(t, f.Search(v))
+		assert.False(t, f.Search(v+"~"))
+	}
+}
+
+func TestFrozen_FloorCeiling(t *testing.T) {
+	trp := NewTreap()
+	for _, v := range []string{"b", "d", "f"} {
+		trp.Insert(v)
+	}
+	path := writeFrozen(t, trp)
+	defer os.Remove(path)
+
+	f, err := OpenFrozen(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	tests := []struct {
+		name        string
+		value       string
+		wantFloor   string
+		wantFloorOk bool
+		wantCeil    string
+		wantCeilOk  bool
+	}{
+		{
+			name:       "value before all values",
+			value:      "a",
+			wantCeil:   "b",
+			wantCeilOk: true,
+		},
+		{
+			name:        "existing value",
+			value:       "d",
+			wantFloor:   "d",
+			wantFloorOk: true,
+			wantCeil:    "d",
+			wantCeilOk:  true,
+		},
+		{
+			name:        "value between values",
+			value:       "e",
+			wantFloor:   "d",
+			wantFloorOk: true,
+			wantCeil:    "f",
+			wantCeilOk:  true,
+		},
+		{
+			name:        "value after all values",
+			value:       "g",
+			wantFloor:   "f",
+			wantFloorOk: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			floor, ok := f.Floor(tt.value)
+			assert.Equal(t, tt.wantFloor, floor)
+			assert.Equal(t, tt.wantFloorOk, ok)
+
+			ceil, ok := f.Ceiling(tt.value)
+			assert.Equal(t, tt.wantCeil, ceil)
+			assert.Equal(t, tt.wantCeilOk, ok)
+		})
+	}
+}
+
+func TestFrozen_Range(t *testing.T) {
+	trp := NewTreap()
+	inserted := fillTree(trp, 2000)
+	path := writeFrozen(t, trp)
+	defer os.Remove(path)
+
+	f, err := OpenFrozen(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	var want []string
+	for k := range inserted {
+		if k >= "f" && k < "m" {
+			want = append(want, k)
+		}
+	}
+	sort.Strings(want)
+
+	var got []string
+	f.Range("f", "m", func(value string) bool {
+		got = append(got, value)
+		return true
+	})
+	assert.Equal(t, want, got)
+
+	got = nil
+	f.IterateFrom("x", func(value string) bool {
+		got = append(got, value)
+		return len(got) < 2
+	})
+	assert.Len(t, got, 2)
+	assert.True(t, got[0] >= "x")
+}
+
+func TestOpenFrozen_Invalid(t *testing.T) {
+	trp := NewTreap()
+	for _, v := range []string{"a", "b", "c", "d"} {
+		trp.Insert(v)
+	}
+	path := writeFrozen(t, trp)
+	defer os.Remove(path)
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		corrupt   func(data []byte) []byte
+		wantOpen  error
+		wantCheck error
+	}{
+		{
+			name: "empty treap",
+			corrupt: func([]byte) []byte {
+				return frozenBytes(t, NewTreap())
+			},
+		},
+		{
+			name: "bad magic",
+			corrupt: func(data []byte) []byte {
+				data[0] = 'X'
+				return data
+			},
+			wantOpen: ErrInvalidFrozen,
+		},
+		{
+			name: "truncated file",
+			corrupt: func(data []byte) []byte {
+				return data[:len(data)-1]
+			},
+			wantOpen: ErrInvalidFrozen,
+		},
+		{
+			name: "child records pointing at their parent",
+			corrupt: func(data []byte) []byte {
+				binary.LittleEndian.PutUint64(
+					data[frozenHeaderSize+16:], frozenHeaderSize)
+				binary.LittleEndian.PutUint64(
+					data[frozenHeaderSize+24:], frozenHeaderSize)
+				return data
+			},
+			wantCheck: ErrInvalidFrozen,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corrupted := tt.corrupt(append([]byte(nil), data...))
+			path := filepath.Join(os.TempDir(), "corrupted.trpf")
+			assert.NoError(t, ioutil.WriteFile(path, corrupted, 0644))
+			defer os.Remove(path)
+
+			f, err := OpenFrozen(path)
+			assert.True(t, errors.Is(err, tt.wantOpen))
+			if err != nil {
+				return
+			}
+			defer f.Close()
+
+			assert.True(t, errors.Is(f.Verify(), tt.wantCheck))
+		})
+	}
+}
+
+// writeFrozen writes the passed Treap to a temporary file in the
+// frozen format and returns the path of the file.
+func writeFrozen(t *testing.T, trp *Treap) string {
+	f, err := ioutil.TempFile("", "treap")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	_, err = trp.WriteFrozen(f)
+	assert.NoError(t, err)
+	return f.Name()
+}
+
+// frozenBytes returns the passed Treap in the frozen format.
+func frozenBytes(t *testing.T, trp *Treap) []byte {
+	path := writeFrozen(t, trp)
+	defer os.Remove(path)
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	return data
+}
@@ -0,0 +1,117 @@
+package treap
+
+// Fuzzy calls fn for each value in the Treap whose Levenshtein distance
+// from the given query is at most maxDist, along with the distance, in
+// ascending order. The distance is the least number of bytes inserted,
+// deleted or substituted to turn the value into the query. Iteration
+// stops early if fn returns false.
+//
+// The values are walked in order, and the rows of the distance table
+// for the prefix a value shares with the previous value are reused.
+// Once every entry of a row is greater than maxDist, every value with
+// the prefix of that row is too far from the query, and is skipped
+// with a seek past the prefix in byte order.
+func (t *Treap) Fuzzy(query string, maxDist int, fn func(value string, dist int) bool) {
+	if maxDist < 0 || t.root == nil {
+		return
+	}
+
+	// rows[i] holds the distances between the first i bytes of prev
+	// and each prefix of the query
+	rows := [][]int{make([]int, len(query)+1)}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	var prev string
+
+	c := newCursor(t.root, t.order)
+	from, seek := "", false
+	for {
+		var n *node
+		var ok bool
+		if seek {
+			n, ok = c.nextFrom(t.root, from)
+		} else {
+			n, ok = c.next()
+		}
+		if !ok {
+			return
+		}
+		value := n.value
+
+		rows = rows[:commonPrefixLen(prev, value)+1]
+		pruned := false
+		for i := len(rows) - 1; i < len(value); i++ {
+			row := nextRow(rows, query, value[i])
+			rows = append(rows, row)
+			if leastDist(row) > maxDist {
+				pruned = true
+				prev = value[:i+1]
+				break
+			}
+		}
+
+		if pruned {
+			// the values with the prefix follow each other
+			// only in byte order
+			if t.order == nil {
+				if from, seek = prefixEnd(prev); !seek {
+					return
+				}
+			}
+			continue
+		}
+
+		prev, seek = value, false
+		if dist := rows[len(value)][len(query)]; dist <= maxDist {
+			if !fn(value, dist) {
+				return
+			}
+		}
+	}
+}
+
+// nextRow returns the row of the distance table that follows the last
+// of the passed rows when the passed byte is appended to its prefix.
+// The row reuses the memory of a row that was previously dropped from
+// the passed rows, if there is one.
+func nextRow(rows [][]int, query string, b byte) []int {
+	prev := rows[len(rows)-1]
+
+	var row []int
+	if len(rows) < cap(rows) {
+		row = rows[:len(rows)+1][len(rows)]
+	}
+	if len(row) != len(prev) {
+		row = make([]int, len(prev))
+	}
+
+	row[0] = prev[0] + 1
+	for j := 1; j < len(row); j++ {
+		cost := 1
+		if query[j-1] == b {
+			cost = 0
+		}
+
+		row[j] = prev[j-1] + cost
+		if d := prev[j] + 1; d < row[j] {
+			row[j] = d
+		}
+		if d := row[j-1] + 1; d < row[j] {
+			row[j] = d
+		}
+	}
+
+	return row
+}
+
+// leastDist returns the least of the passed distances.
+func leastDist(dists []int) int {
+	least := dists[0]
+	for _, d := range dists[1:] {
+		if d < least {
+			least = d
+		}
+	}
+	return least
+}
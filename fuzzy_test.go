@@ -0,0 +1,141 @@
+package treap
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fuzzyMatch is a value reported by Fuzzy.
+type fuzzyMatch struct {
+	value string
+	dist  int
+}
+
+func TestTreap_Fuzzy(t *testing.T) {
+	values := []string{
+		"", "commit", "comet", "compile", "config", "cont", "push", "pull",
+	}
+	tests := []struct {
+		name    string
+		query   string
+		maxDist int
+		want    []fuzzyMatch
+	}{
+		{
+			name:    "exact match",
+			query:   "push",
+			maxDist: 0,
+			want:    []fuzzyMatch{{"push", 0}},
+		},
+		{
+			name:    "one typo",
+			query:   "comit",
+			maxDist: 1,
+			want:    []fuzzyMatch{{"comet", 1}, {"commit", 1}},
+		},
+		{
+			name:    "two typos",
+			query:   "pudh",
+			maxDist: 2,
+			want:    []fuzzyMatch{{"pull", 2}, {"push", 1}},
+		},
+		{
+			name:    "empty value",
+			query:   "a",
+			maxDist: 1,
+			want:    []fuzzyMatch{{"", 1}},
+		},
+		{
+			name:    "empty query",
+			query:   "",
+			maxDist: 4,
+			want: []fuzzyMatch{
+				{"", 0}, {"cont", 4}, {"pull", 4}, {"push", 4},
+			},
+		},
+		{
+			name:    "negative distance",
+			query:   "push",
+			maxDist: -1,
+		},
+		{
+			name:    "no matches",
+			query:   "xyzzy",
+			maxDist: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trp := NewTreap()
+			for _, v := range values {
+				trp.Insert(v)
+			}
+
+			var got []fuzzyMatch
+			trp.Fuzzy(tt.query, tt.maxDist, func(value string, dist int) bool {
+				got = append(got, fuzzyMatch{value, dist})
+				return true
+			})
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTreap_Fuzzy_Exhaustive(t *testing.T) {
+	trp := NewTreap()
+	fillTree(trp, 2000)
+
+	for _, query := range []string{"", "a", "abc", "hello", "zzzzzz"} {
+		for maxDist := 0; maxDist <= 3; maxDist++ {
+			t.Run(fmt.Sprintf("%q within %d", query, maxDist), func(t *testing.T) {
+				var want []fuzzyMatch
+				trp.Iterate(func(value string) bool {
+					if d := levenshtein(value, query); d <= maxDist {
+						want = append(want, fuzzyMatch{value, d})
+					}
+					return true
+				})
+
+				var got []fuzzyMatch
+				trp.Fuzzy(query, maxDist, func(value string, dist int) bool {
+					got = append(got, fuzzyMatch{value, dist})
+					return true
+				})
+				assert.Equal(t, want, got)
+			})
+		}
+	}
+}
+
+// levenshtein returns the Levenshtein distance between the passed strings.
+func levenshtein(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = d[i-1][j-1] + cost
+			if d[i-1][j]+1 < d[i][j] {
+				d[i][j] = d[i-1][j] + 1
+			}
+			if d[i][j-1]+1 < d[i][j] {
+				d[i][j] = d[i][j-1] + 1
+			}
+		}
+	}
+
+	return d[len(a)][len(b)]
+}
@@ -0,0 +1,164 @@
+package treap
+
+import "errors"
+
+// ErrCheckpointNotFound is returned when restoring a checkpoint that
+// was never made or is no longer in the history of the Treap.
+var ErrCheckpointNotFound = errors.New("treap: checkpoint not found")
+
+// history is a bounded record of the mutations of a Treap.
+type history struct {
+	// ops holds the recorded mutations. The first pos of them are
+	// applied to the Treap and the rest have been undone.
+	ops []operation
+	pos int
+
+	// limit is the maximum length of ops, or zero if unbounded.
+	limit int
+
+	// dropped is the number of mutations dropped from the front of ops.
+	dropped int
+
+	// checkpoints maps checkpoint names to the number of mutations
+	// recorded before them, including dropped mutations.
+	checkpoints map[string]int
+
+	// replaying is true while undoing or redoing a mutation.
+	replaying bool
+}
+
+// EnableHistory makes the Treap record each Insert and Delete that
+// changes its contents, so that they can be undone and redone.
+// At most limit mutations are recorded, dropping the oldest ones first.
+// A limit of zero or less records an unbounded number of mutations.
+func (t *Treap) EnableHistory(limit int) {
+	if t.history != nil {
+		return
+	}
+
+	t.history = &history{
+		limit:       limit,
+		checkpoints: make(map[string]int),
+	}
+}
+
+// Undo reverts the most recent mutation of the Treap that isn't undone.
+// Returns false if there is no mutation to undo.
+func (t *Treap) Undo() bool {
+	h := t.history
+	if h == nil || h.pos == 0 {
+		return false
+	}
+
+	h.pos--
+	t.replay(h.ops[h.pos].inverse())
+	return true
+}
+
+// Redo reapplies the most recently undone mutation of the Treap.
+// Any mutation other than Undo or Redo discards the mutations
+// that are available to redo.
+// Returns false if there is no mutation to redo.
+func (t *Treap) Redo() bool {
+	h := t.history
+	if h == nil || h.pos == len(h.ops) {
+		return false
+	}
+
+	t.replay(h.ops[h.pos])
+	h.pos++
+	return true
+}
+
+// Checkpoint names the current state of the Treap in its history so
+// that it can be restored with RestoreCheckpoint. Making a checkpoint
+// with an existing name moves the checkpoint.
+// Checkpoint does nothing if history isn't enabled.
+func (t *Treap) Checkpoint(name string) {
+	if t.history == nil {
+		return
+	}
+
+	t.history.checkpoints[name] = t.history.dropped + t.history.pos
+}
+
+// RestoreCheckpoint undoes or redoes mutations of the Treap until it's
+// in the state named by the given checkpoint.
+// Returns ErrCheckpointNotFound if the checkpoint was never made,
+// or if the mutations needed to restore it are no longer recorded.
+func (t *Treap) RestoreCheckpoint(name string) error {
+	h := t.history
+	if h == nil {
+		return ErrCheckpointNotFound
+	}
+
+	target, ok := h.checkpoints[name]
+	if !ok || target < h.dropped || target > h.dropped+len(h.ops) {
+		return ErrCheckpointNotFound
+	}
+
+	for h.dropped+h.pos > target {
+		t.Undo()
+	}
+	for h.dropped+h.pos < target {
+		t.Redo()
+	}
+
+	return nil
+}
+
+// replay applies the passed mutation to the Treap without recording it.
+func (t *Treap) replay(op operation) {
+	t.history.replaying = true
+	defer func() { t.history.replaying = false }()
+
+	if op.insert {
+		t.Insert(op.value)
+	} else {
+		t.Delete(op.value)
+	}
+}
+
+// record records the passed mutation unless it's being replayed.
+func (h *history) record(op operation) {
+	if h.replaying {
+		return
+	}
+
+	// a new mutation discards the mutations available to redo,
+	// along with the checkpoints that they led to
+	if h.pos < len(h.ops) {
+		checkpoints := make(map[string]int, len(h.checkpoints))
+		for name, pos := range h.checkpoints {
+			if pos <= h.dropped+h.pos {
+				checkpoints[name] = pos
+			}
+		}
+		h.checkpoints = checkpoints
+	}
+	h.ops = append(h.ops[:h.pos], op)
+	h.pos++
+
+	if h.limit > 0 && len(h.ops) > h.limit {
+		n := len(h.ops) - h.limit
+		h.ops = append(h.ops[:0], h.ops[n:]...)
+		h.pos -= n
+		h.dropped += n
+	}
+}
+
+// clear discards all recorded mutations and checkpoints.
+func (h *history) clear() {
+	h.ops = nil
+	h.pos = 0
+	h.dropped = 0
+	h.checkpoints = make(map[string]int)
+}
+
+// inverse returns the mutation that reverts the operation.
+func (op operation) inverse() operation {
+	return operation{
+		insert: !op.insert,
+		value:  op.value,
+	}
+}
@@ -0,0 +1,114 @@
+package treap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreap_UndoRedo(t *testing.T) {
+	trp := NewTreap()
+	trp.Insert("a")
+	trp.EnableHistory(0)
+
+	// Assert that nothing before enabling history can be undone
+	assert.False(t, trp.Undo())
+
+	trp.Insert("b")
+	trp.Insert("c")
+	trp.Delete("a")
+	trp.Insert("b")
+	assert.Equal(t, []string{"b", "c"}, collect(trp))
+
+	assert.True(t, trp.Undo())
+	assert.Equal(t, []string{"a", "b", "c"}, collect(trp))
+	assert.True(t, trp.Undo())
+	assert.Equal(t, []string{"a", "b"}, collect(trp))
+
+	assert.True(t, trp.Redo())
+	assert.Equal(t, []string{"a", "b", "c"}, collect(trp))
+
+	assert.True(t, trp.Undo())
+	assert.True(t, trp.Undo())
+	assert.False(t, trp.Undo())
+	assert.Equal(t, []string{"a"}, collect(trp))
+
+	// Assert that a new mutation discards the mutations to redo
+	trp.Insert("d")
+	assert.False(t, trp.Redo())
+	assert.True(t, trp.Undo())
+	assert.Equal(t, []string{"a"}, collect(trp))
+}
+
+func TestTreap_UndoDisabled(t *testing.T) {
+	trp := NewTreap()
+	trp.Insert("a")
+	assert.False(t, trp.Undo())
+	assert.False(t, trp.Redo())
+	assert.Equal(t, ErrCheckpointNotFound, trp.RestoreCheckpoint("x"))
+}
+
+func TestTreap_HistoryLimit(t *testing.T) {
+	trp := NewTreap()
+	trp.EnableHistory(2)
+	trp.Insert("a")
+	trp.Insert("b")
+	trp.Insert("c")
+
+	assert.True(t, trp.Undo())
+	assert.True(t, trp.Undo())
+	assert.False(t, trp.Undo())
+	assert.Equal(t, []string{"a"}, collect(trp))
+}
+
+func TestTreap_RestoreCheckpoint(t *testing.T) {
+	trp := NewTreap()
+	trp.EnableHistory(4)
+	trp.Checkpoint("empty")
+	trp.Insert("a")
+	trp.Insert("b")
+	trp.Checkpoint("ab")
+	trp.Delete("a")
+	trp.Insert("c")
+	trp.Checkpoint("bc")
+
+	tests := []struct {
+		name       string
+		checkpoint string
+		want       []string
+		wantErr    error
+	}{
+		{
+			name:       "restore checkpoint by undoing",
+			checkpoint: "ab",
+			want:       []string{"a", "b"},
+		},
+		{
+			name:       "restore checkpoint by redoing",
+			checkpoint: "bc",
+			want:       []string{"b", "c"},
+		},
+		{
+			name:       "restore unknown checkpoint",
+			checkpoint: "x",
+			want:       []string{"b", "c"},
+			wantErr:    ErrCheckpointNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, trp.RestoreCheckpoint(tt.checkpoint))
+			assert.Equal(t, tt.want, collect(trp))
+		})
+	}
+
+	// Assert that checkpoints beyond the history limit are lost
+	trp.Insert("d")
+	assert.Equal(t, ErrCheckpointNotFound, trp.RestoreCheckpoint("empty"))
+	assert.NoError(t, trp.RestoreCheckpoint("ab"))
+	assert.Equal(t, []string{"a", "b"}, collect(trp))
+
+	// Assert that checkpoints on a discarded redo branch are lost
+	trp.Insert("e")
+	assert.Equal(t, ErrCheckpointNotFound, trp.RestoreCheckpoint("bc"))
+}
@@ -0,0 +1,122 @@
+package treap
+
+import "strings"
+
+// ListResult is a page of the values of a Treap listed by List.
+type ListResult struct {
+	// Keys holds the listed values that aren't rolled up
+	// into a common prefix, in ascending order.
+	Keys []string
+
+	// CommonPrefixes holds the distinct prefixes that the listed values
+	// are rolled up into, in ascending order. A common prefix runs up to
+	// and including the first delimiter after the listed prefix.
+	CommonPrefixes []string
+
+	// IsTruncated is true if there are more keys or common prefixes
+	// to list after those in the page.
+	IsTruncated bool
+
+	// NextContinuationToken is passed to ListContinue to list the next
+	// page when IsTruncated is true.
+	NextContinuationToken string
+}
+
+// List lists the values of the Treap that start with the given prefix,
+// in the manner of listing the objects of a bucket in an object store.
+// Values that contain the given delimiter after the prefix are rolled up
+// into a common prefix, and the rest are listed as keys. The values are
+// listed in ascending order from after the given start after value, up to
+// maxKeys keys and common prefixes. A maxKeys of zero or less lists all
+// of them. An empty delimiter lists every value as a key. Values after
+// the start after value are still rolled up into a common prefix that
+// also holds the start after value itself.
+//
+// The values rolled up into a common prefix aren't enumerated. Instead,
+// the listing seeks past them, so a page takes a logarithmic time for
+// each key and common prefix in it. The listing is always in byte order,
+// which in other orders takes sorting the values with the prefix.
+func (t *Treap) List(prefix, delimiter, startAfter string, maxKeys int) *ListResult {
+	from := prefix
+	if startAfter != "" && startAfter >= prefix {
+		from = startAfter + "\x00"
+	}
+
+	return t.list(prefix, delimiter, from, true, maxKeys)
+}
+
+// ListContinue lists the next page of the values of the Treap after a
+// page listed by List or ListContinue with the same prefix and delimiter.
+// The given token is the NextContinuationToken of that page, and the
+// listing resumes after the key or the whole common prefix that the
+// page ended with.
+func (t *Treap) ListContinue(prefix, delimiter, token string, maxKeys int) *ListResult {
+	if token == "" || token < prefix {
+		return t.list(prefix, delimiter, prefix, true, maxKeys)
+	}
+
+	from, ok := listAfter(prefix, delimiter, token)
+	return t.list(prefix, delimiter, from, ok, maxKeys)
+}
+
+// list lists the values of the Treap that start with the passed prefix
+// from the passed value onwards, or lists nothing if ok is false.
+func (t *Treap) list(prefix, delimiter, from string, ok bool, maxKeys int) *ListResult {
+	result := &ListResult{}
+	view := t.byteView(prefix)
+
+	for count := 0; ok; count++ {
+		value, found := view.ceiling(from)
+		if !found || !strings.HasPrefix(value, prefix) {
+			break
+		}
+		if maxKeys > 0 && count == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+
+		var last string
+		if common, rolled := rollUp(prefix, delimiter, value); rolled {
+			result.CommonPrefixes = append(result.CommonPrefixes, common)
+			last = common
+		} else {
+			result.Keys = append(result.Keys, value)
+			last = value
+		}
+
+		result.NextContinuationToken = last
+		from, ok = listAfter(prefix, delimiter, last)
+	}
+
+	if !result.IsTruncated {
+		result.NextContinuationToken = ""
+	}
+	return result
+}
+
+// listAfter returns the value to seek from to list the values after
+// the passed key or common prefix, and false if there is no such value.
+func listAfter(prefix, delimiter, last string) (string, bool) {
+	if common, rolled := rollUp(prefix, delimiter, last); rolled {
+		// every value that starts with a common prefix
+		// is rolled up into it
+		return prefixEnd(common)
+	}
+
+	return last + "\x00", true
+}
+
+// rollUp returns the common prefix that the passed value is rolled up
+// into when listing the passed prefix with the passed delimiter, and
+// false if the value isn't rolled up.
+func rollUp(prefix, delimiter, value string) (string, bool) {
+	if delimiter == "" || !strings.HasPrefix(value, prefix) {
+		return "", false
+	}
+
+	i := strings.Index(value[len(prefix):], delimiter)
+	if i < 0 {
+		return "", false
+	}
+	return value[:len(prefix)+i+len(delimiter)], true
+}
@@ -0,0 +1,225 @@
+package treap
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listValues are the values of the Treaps in the list tests.
+var listValues = []string{
+	"a.txt",
+	"docs/",
+	"docs/a.md",
+	"docs/b/c.md",
+	"docs/z.md",
+	"photos/2020/a.jpg",
+	"photos/2021/b.jpg",
+	"photos/c.jpg",
+	"z.txt",
+}
+
+func TestTreap_List(t *testing.T) {
+	tests := []struct {
+		name       string
+		prefix     string
+		delimiter  string
+		startAfter string
+		token      string
+		maxKeys    int
+		want       *ListResult
+	}{
+		{
+			name:      "root",
+			delimiter: "/",
+			want: &ListResult{
+				Keys:           []string{"a.txt", "z.txt"},
+				CommonPrefixes: []string{"docs/", "photos/"},
+			},
+		},
+		{
+			name:      "directory",
+			prefix:    "docs/",
+			delimiter: "/",
+			want: &ListResult{
+				Keys:           []string{"docs/", "docs/a.md", "docs/z.md"},
+				CommonPrefixes: []string{"docs/b/"},
+			},
+		},
+		{
+			name:      "nested directories",
+			prefix:    "photos/",
+			delimiter: "/",
+			want: &ListResult{
+				Keys:           []string{"photos/c.jpg"},
+				CommonPrefixes: []string{"photos/2020/", "photos/2021/"},
+			},
+		},
+		{
+			name:   "no delimiter",
+			prefix: "photos/",
+			want: &ListResult{
+				Keys: []string{
+					"photos/2020/a.jpg",
+					"photos/2021/b.jpg",
+					"photos/c.jpg",
+				},
+			},
+		},
+		{
+			name:      "partial name prefix",
+			prefix:    "photos/202",
+			delimiter: "/",
+			want: &ListResult{
+				CommonPrefixes: []string{"photos/2020/", "photos/2021/"},
+			},
+		},
+		{
+			name:      "truncated",
+			delimiter: "/",
+			maxKeys:   2,
+			want: &ListResult{
+				Keys:                  []string{"a.txt"},
+				CommonPrefixes:        []string{"docs/"},
+				IsTruncated:           true,
+				NextContinuationToken: "docs/",
+			},
+		},
+		{
+			name:       "after a key that is a common prefix",
+			delimiter:  "/",
+			startAfter: "docs/",
+			want: &ListResult{
+				Keys:           []string{"z.txt"},
+				CommonPrefixes: []string{"docs/", "photos/"},
+			},
+		},
+		{
+			name:       "after a key within a common prefix",
+			delimiter:  "/",
+			startAfter: "docs/b/c.md",
+			want: &ListResult{
+				Keys:           []string{"z.txt"},
+				CommonPrefixes: []string{"docs/", "photos/"},
+			},
+		},
+		{
+			name:       "after the last key of a common prefix",
+			delimiter:  "/",
+			startAfter: "docs/z.md",
+			want: &ListResult{
+				Keys:           []string{"z.txt"},
+				CommonPrefixes: []string{"photos/"},
+			},
+		},
+		{
+			name:      "continued after a common prefix",
+			delimiter: "/",
+			token:     "docs/",
+			want: &ListResult{
+				Keys:           []string{"z.txt"},
+				CommonPrefixes: []string{"photos/"},
+			},
+		},
+		{
+			name:      "continued after a key",
+			prefix:    "docs/",
+			delimiter: "/",
+			token:     "docs/a.md",
+			want: &ListResult{
+				Keys:           []string{"docs/z.md"},
+				CommonPrefixes: []string{"docs/b/"},
+			},
+		},
+		{
+			name:       "after a key",
+			delimiter:  "/",
+			startAfter: "a.txt",
+			maxKeys:    1,
+			want: &ListResult{
+				CommonPrefixes:        []string{"docs/"},
+				IsTruncated:           true,
+				NextContinuationToken: "docs/",
+			},
+		},
+		{
+			name:    "exactly max keys",
+			prefix:  "docs/b/",
+			maxKeys: 1,
+			want: &ListResult{
+				Keys: []string{"docs/b/c.md"},
+			},
+		},
+		{
+			name:   "no values",
+			prefix: "music/",
+			want:   &ListResult{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trp := NewTreap()
+			for _, v := range listValues {
+				trp.Insert(v)
+			}
+
+			got := trp.List(tt.prefix, tt.delimiter, tt.startAfter, tt.maxKeys)
+			if tt.token != "" {
+				got = trp.ListContinue(tt.prefix, tt.delimiter, tt.token, tt.maxKeys)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTreap_List_StartAfter(t *testing.T) {
+	trp := NewTreap()
+	for _, v := range []string{"a/1", "a/2", "a/3", "b", "c/1"} {
+		trp.Insert(v)
+	}
+
+	// Assert that values after a key within a common prefix are listed
+	got := trp.List("", "/", "a/1", 0)
+	assert.Equal(t, &ListResult{
+		Keys:           []string{"b"},
+		CommonPrefixes: []string{"a/", "c/"},
+	}, got)
+
+	got = trp.List("", "/", "a/3", 0)
+	assert.Equal(t, &ListResult{
+		Keys:           []string{"b"},
+		CommonPrefixes: []string{"c/"},
+	}, got)
+}
+
+func TestTreap_List_Pages(t *testing.T) {
+	trp := NewTreap()
+	var want []string
+	for i := 0; i < 50; i++ {
+		dir := fmt.Sprintf("dir%02d/", i)
+		want = append(want, dir)
+		for j := 0; j < 100; j++ {
+			trp.Insert(fmt.Sprintf("%sfile%02d", dir, j))
+		}
+
+		file := fmt.Sprintf("file%02d", i)
+		want = append(want, file)
+		trp.Insert(file)
+	}
+
+	var got []string
+	token := ""
+	for {
+		page := trp.ListContinue("", "/", token, 7)
+		got = append(got, page.CommonPrefixes...)
+		got = append(got, page.Keys...)
+		assert.True(t, len(page.Keys)+len(page.CommonPrefixes) <= 7)
+		if !page.IsTruncated {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+
+	assert.ElementsMatch(t, want, got)
+}
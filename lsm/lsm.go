@@ -0,0 +1,614 @@
+// Package lsm provides a sorted set of strings that can hold more values
+// than fit in memory, by buffering mutations in a Treap and flushing
+// them to immutable sorted run files that are merged in the background.
+package lsm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/austingebauer/go-treap"
+)
+
+const (
+	// The names of the files kept in the directory of a DB.
+	manifestName     = "MANIFEST"
+	manifestTempName = "MANIFEST.tmp"
+	runExt           = ".run"
+)
+
+// ErrClosed is returned when using a DB that has been closed.
+var ErrClosed = errors.New("lsm: closed")
+
+// Options configures a DB.
+type Options struct {
+	// MemtableSize is the number of mutations buffered in the
+	// memtable before it's flushed to a run. Zero or less flushes
+	// the memtable only on Flush or Close.
+	MemtableSize int
+
+	// CompactAt is the number of runs at which a background
+	// compaction merges all runs into one. Zero or less disables
+	// background compactions.
+	CompactAt int
+}
+
+// DefaultOptions are the Options used by Open.
+var DefaultOptions = Options{
+	MemtableSize: 100000,
+	CompactAt:    4,
+}
+
+// DB is a sorted set of strings whose mutations are buffered in an
+// in-memory Treap, called the memtable, and flushed to immutable run
+// files on disk. Deleted values are recorded as tombstones until a
+// compaction merges all runs. Reads merge the memtable and the runs,
+// preferring the newest entry for each value.
+//
+// Runs are durable once flushed, but mutations still buffered in the
+// memtable are lost if the process exits without calling Flush or Close.
+// A DB is safe for concurrent use.
+type DB struct {
+	mu   sync.RWMutex
+	dir  string
+	opts Options
+
+	// mem holds the inserted values in the memtable,
+	// and tombstones holds the deleted values.
+	mem        *treap.Treap
+	tombstones *treap.Treap
+	buffered   int
+
+	// runs holds the open runs from newest to oldest.
+	runs   []*run
+	nextID uint64
+
+	// compactMu serializes compactions.
+	compactMu sync.Mutex
+
+	// bgMu guards compacting, which is true while a background
+	// compaction is in progress, and err, which is the first error
+	// from a background compaction. When both mu and bgMu are held,
+	// mu is acquired first.
+	bgMu       sync.Mutex
+	compacting bool
+	err        error
+	wg         sync.WaitGroup
+
+	closed bool
+}
+
+// Open opens the DB in the given directory using DefaultOptions,
+// creating the directory if it doesn't exist.
+func Open(dir string) (*DB, error) {
+	return OpenWithOptions(dir, DefaultOptions)
+}
+
+// OpenWithOptions opens the DB in the given directory using the given
+// options, creating the directory if it doesn't exist. Run files that
+// aren't listed in the manifest, such as those left by an interrupted
+// flush or compaction, are removed.
+func OpenWithOptions(dir string, opts Options) (*DB, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	db := &DB{
+		dir:        dir,
+		opts:       opts,
+		mem:        treap.NewTreap(),
+		tombstones: treap.NewTreap(),
+	}
+
+	ids, err := db.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.removeStrayRuns(ids); err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		r, err := openRun(id, db.runPath(id))
+		if err != nil {
+			db.closeRuns(db.runs)
+			return nil, err
+		}
+		db.runs = append(db.runs, r)
+	}
+
+	return db, nil
+}
+
+// Search returns true if the given value is in the DB.
+// Otherwise, returns false.
+func (db *DB) Search(value string) (bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return false, ErrClosed
+	}
+	if db.mem.Search(value) {
+		return true, nil
+	}
+	if db.tombstones.Search(value) {
+		return false, nil
+	}
+
+	for _, r := range db.runs {
+		found, tombstone, err := r.lookup(value)
+		if err != nil {
+			return false, err
+		}
+		if found {
+			return !tombstone, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Iterate calls fn for each value in the DB in ascending order.
+// Iteration stops early if fn returns false. The DB can't be
+// mutated by fn.
+func (db *DB) Iterate(fn func(value string) bool) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return ErrClosed
+	}
+
+	its := []iterator{db.memIterator()}
+	for _, r := range db.runs {
+		its = append(its, &runIterator{r: r})
+	}
+
+	m := newMergeIterator(its...)
+	for e, ok := m.next(); ok; e, ok = m.next() {
+		if !e.tombstone && !fn(e.value) {
+			return nil
+		}
+	}
+
+	return m.error()
+}
+
+// Insert inserts the given value into the DB.
+func (db *DB) Insert(value string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return ErrClosed
+	}
+
+	db.tombstones.Delete(value)
+	db.mem.Insert(value)
+	return db.buffer()
+}
+
+// Delete deletes the given value from the DB.
+func (db *DB) Delete(value string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return ErrClosed
+	}
+
+	db.mem.Delete(value)
+	db.tombstones.Insert(value)
+	return db.buffer()
+}
+
+// Flush flushes the memtable of the DB to a new run.
+func (db *DB) Flush() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return ErrClosed
+	}
+	return db.flush()
+}
+
+// Compact merges all runs of the DB into one, dropping tombstones and
+// the values that they delete. The memtable isn't flushed first.
+func (db *DB) Compact() error {
+	// the compaction is tracked like a background one,
+	// so Close waits for it before closing the runs
+	db.mu.RLock()
+	if db.closed {
+		db.mu.RUnlock()
+		return ErrClosed
+	}
+	db.wg.Add(1)
+	db.mu.RUnlock()
+	defer db.wg.Done()
+
+	return db.compact()
+}
+
+// Close flushes the memtable of the DB, waits for any background
+// compaction to finish, and closes the DB.
+// Returns the first error from a background compaction, if any.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return ErrClosed
+	}
+
+	// the DB is closed before the final flush,
+	// so that it doesn't start a compaction
+	db.closed = true
+	err := db.flush()
+	db.mu.Unlock()
+
+	db.wg.Wait()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.closeRuns(db.runs)
+	db.runs = nil
+
+	if err == nil {
+		err = db.backgroundErr()
+	}
+	return err
+}
+
+// buffer counts a mutation buffered in the memtable, flushing the
+// memtable if it's full. It must be called with mu held.
+func (db *DB) buffer() error {
+	db.buffered++
+	if db.opts.MemtableSize <= 0 || db.buffered < db.opts.MemtableSize {
+		return nil
+	}
+	return db.flush()
+}
+
+// flush writes the memtable to a new run, then starts a background
+// compaction if there are enough runs and the DB isn't closed. It must
+// be called with mu held.
+func (db *DB) flush() error {
+	if db.buffered == 0 {
+		return nil
+	}
+	if err := db.backgroundErr(); err != nil {
+		return err
+	}
+
+	id := db.nextID
+	w, err := createRun(db.runPath(id))
+	if err != nil {
+		return err
+	}
+
+	it := db.memIterator()
+	for e, ok := it.next(); ok; e, ok = it.next() {
+		if err := w.add(e); err != nil {
+			w.abort()
+			return err
+		}
+	}
+	if err := w.finish(); err != nil {
+		os.Remove(w.f.Name())
+		return err
+	}
+
+	r, err := openRun(id, db.runPath(id))
+	if err != nil {
+		return err
+	}
+	runs := append([]*run{r}, db.runs...)
+	if err := db.writeManifest(runs); err != nil {
+		r.close()
+		os.Remove(db.runPath(id))
+		return err
+	}
+
+	db.nextID++
+	db.runs = runs
+	db.mem = treap.NewTreap()
+	db.tombstones = treap.NewTreap()
+	db.buffered = 0
+
+	if !db.closed && db.opts.CompactAt > 0 && len(db.runs) >= db.opts.CompactAt {
+		db.compactInBackground()
+	}
+	return nil
+}
+
+// compactInBackground starts a background compaction unless one is
+// already in progress. It must be called with mu held.
+func (db *DB) compactInBackground() {
+	db.bgMu.Lock()
+	defer db.bgMu.Unlock()
+
+	if db.compacting {
+		return
+	}
+	db.compacting = true
+
+	db.wg.Add(1)
+	go func() {
+		defer db.wg.Done()
+
+		err := db.compact()
+
+		db.bgMu.Lock()
+		defer db.bgMu.Unlock()
+		db.compacting = false
+		if err != nil && err != ErrClosed && db.err == nil {
+			db.err = err
+		}
+	}()
+}
+
+// compact merges all current runs into one. Runs flushed while the
+// merge is in progress are newer, so they're kept ahead of the merged run.
+// Returns ErrClosed without merging if the DB is closed, or discarding
+// the merged run if the DB was closed or its runs otherwise changed
+// during the merge. It must be called with a count added to wg, so that
+// Close waits for it.
+func (db *DB) compact() error {
+	db.compactMu.Lock()
+	defer db.compactMu.Unlock()
+
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return ErrClosed
+	}
+	inputs := append([]*run(nil), db.runs...)
+	id := db.nextID
+	db.nextID++
+	db.mu.Unlock()
+
+	if len(inputs) < 2 {
+		return nil
+	}
+
+	// the oldest run is merged, so no older entry can be
+	// shadowed by a tombstone and tombstones are dropped
+	its := make([]iterator, 0, len(inputs))
+	for _, r := range inputs {
+		its = append(its, &runIterator{r: r})
+	}
+	w, err := createRun(db.runPath(id))
+	if err != nil {
+		return err
+	}
+
+	m := newMergeIterator(its...)
+	for e, ok := m.next(); ok; e, ok = m.next() {
+		if e.tombstone {
+			continue
+		}
+		if err := w.add(e); err != nil {
+			w.abort()
+			return err
+		}
+	}
+	if err := m.error(); err != nil {
+		w.abort()
+		return err
+	}
+	if err := w.finish(); err != nil {
+		os.Remove(w.f.Name())
+		return err
+	}
+
+	merged, err := openRun(id, db.runPath(id))
+	if err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// the inputs are the oldest runs, since runs are only added
+	// ahead of them and only compactions remove them
+	if db.closed || !hasTail(db.runs, inputs) {
+		merged.close()
+		os.Remove(db.runPath(id))
+		return ErrClosed
+	}
+	newer := db.runs[:len(db.runs)-len(inputs)]
+	runs := append(append([]*run(nil), newer...), merged)
+	if err := db.writeManifest(runs); err != nil {
+		merged.close()
+		os.Remove(db.runPath(id))
+		return err
+	}
+
+	db.runs = runs
+	db.closeRuns(inputs)
+	for _, r := range inputs {
+		os.Remove(db.runPath(r.id))
+	}
+
+	return nil
+}
+
+// hasTail returns true if the passed runs end with the passed tail.
+func hasTail(runs, tail []*run) bool {
+	if len(tail) > len(runs) {
+		return false
+	}
+
+	runs = runs[len(runs)-len(tail):]
+	for i := range tail {
+		if runs[i] != tail[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// memIterator returns an iterator over the memtable, including its
+// tombstones. It must be called with mu held.
+func (db *DB) memIterator() iterator {
+	var entries []entry
+	db.mem.Iterate(func(value string) bool {
+		entries = append(entries, entry{value: value})
+		return true
+	})
+
+	// merge the tombstones into the inserted values, which are disjoint
+	var merged []entry
+	db.tombstones.Iterate(func(value string) bool {
+		for len(entries) > 0 && entries[0].value < value {
+			merged = append(merged, entries[0])
+			entries = entries[1:]
+		}
+		merged = append(merged, entry{value: value, tombstone: true})
+		return true
+	})
+
+	return &sliceIterator{entries: append(merged, entries...)}
+}
+
+// backgroundErr returns the first error from a background compaction.
+func (db *DB) backgroundErr() error {
+	db.bgMu.Lock()
+	defer db.bgMu.Unlock()
+	return db.err
+}
+
+// readManifest reads the ids of the runs listed in the manifest from
+// newest to oldest, and sets the next run id past every run file.
+func (db *DB) readManifest() ([]uint64, error) {
+	files, err := ioutil.ReadDir(db.dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if id, ok := parseRunName(f.Name()); ok && id >= db.nextID {
+			db.nextID = id + 1
+		}
+	}
+
+	f, err := os.Open(filepath.Join(db.dir, manifestName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ids []uint64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		id, ok := parseRunName(scanner.Text())
+		if !ok {
+			return nil, fmt.Errorf("lsm: bad manifest entry %q", scanner.Text())
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, scanner.Err()
+}
+
+// writeManifest atomically replaces the manifest with one that
+// lists the passed runs.
+func (db *DB) writeManifest(runs []*run) error {
+	var b strings.Builder
+	for _, r := range runs {
+		b.WriteString(runName(r.id))
+		b.WriteByte('\n')
+	}
+
+	temp := filepath.Join(db.dir, manifestTempName)
+	f, err := os.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temp, filepath.Join(db.dir, manifestName)); err != nil {
+		return err
+	}
+
+	return syncDir(db.dir)
+}
+
+// removeStrayRuns removes the run files that aren't in the passed ids.
+func (db *DB) removeStrayRuns(ids []uint64) error {
+	live := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		live[id] = true
+	}
+
+	files, err := ioutil.ReadDir(db.dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if id, ok := parseRunName(f.Name()); ok && !live[id] {
+			if err := os.Remove(filepath.Join(db.dir, f.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// closeRuns closes the passed runs.
+func (db *DB) closeRuns(runs []*run) {
+	for _, r := range runs {
+		r.close()
+	}
+}
+
+// runPath returns the path of the run file with the passed id.
+func (db *DB) runPath(id uint64) string {
+	return filepath.Join(db.dir, runName(id))
+}
+
+// runName returns the name of the run file with the passed id.
+func runName(id uint64) string {
+	return fmt.Sprintf("%016x%s", id, runExt)
+}
+
+// parseRunName returns the id of the run file with the passed name,
+// and false if it isn't the name of a run file.
+func parseRunName(name string) (uint64, bool) {
+	if !strings.HasSuffix(name, runExt) {
+		return 0, false
+	}
+
+	id, err := strconv.ParseUint(strings.TrimSuffix(name, runExt), 16, 64)
+	return id, err == nil
+}
+
+// syncDir fsyncs the passed directory so that renames within it are durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
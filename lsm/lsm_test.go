@@ -0,0 +1,284 @@
+package lsm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpen(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	db, err := Open(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Insert("c"))
+	assert.NoError(t, db.Insert("a"))
+	assert.NoError(t, db.Insert("b"))
+	assert.NoError(t, db.Delete("c"))
+	assert.NoError(t, db.Close())
+
+	// Assert that the memtable was flushed by Close
+	db, err = Open(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, values(t, db))
+	assert.NoError(t, db.Close())
+
+	assert.Equal(t, ErrClosed, db.Insert("d"))
+	assert.Equal(t, ErrClosed, db.Close())
+}
+
+func TestDB_Search(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	db, err := OpenWithOptions(dir, Options{})
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// oldest run
+	assert.NoError(t, db.Insert("a"))
+	assert.NoError(t, db.Insert("b"))
+	assert.NoError(t, db.Insert("c"))
+	assert.NoError(t, db.Flush())
+
+	// newer run with a tombstone
+	assert.NoError(t, db.Delete("b"))
+	assert.NoError(t, db.Insert("d"))
+	assert.NoError(t, db.Flush())
+
+	// memtable
+	assert.NoError(t, db.Delete("c"))
+	assert.NoError(t, db.Insert("b"))
+	assert.NoError(t, db.Insert("e"))
+
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{
+			name:  "value in oldest run",
+			value: "a",
+			want:  true,
+		},
+		{
+			name:  "value reinserted in memtable",
+			value: "b",
+			want:  true,
+		},
+		{
+			name:  "value deleted in memtable",
+			value: "c",
+			want:  false,
+		},
+		{
+			name:  "value in newer run",
+			value: "d",
+			want:  true,
+		},
+		{
+			name:  "value in memtable",
+			value: "e",
+			want:  true,
+		},
+		{
+			name:  "nonexistent value",
+			value: "f",
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.Search(tt.value)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	assert.Equal(t, []string{"a", "b", "d", "e"}, values(t, db))
+}
+
+func TestDB_Compact(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	db, err := OpenWithOptions(dir, Options{
+		MemtableSize: 100,
+		CompactAt:    3,
+	})
+	assert.NoError(t, err)
+
+	want := make(map[string]bool)
+	for i := 0; i < 2000; i++ {
+		v := fmt.Sprintf("%04d", i*7%1000)
+		if i%3 == 0 {
+			assert.NoError(t, db.Delete(v))
+			delete(want, v)
+		} else {
+			assert.NoError(t, db.Insert(v))
+			want[v] = true
+		}
+	}
+	assert.NoError(t, db.Close())
+
+	db, err = OpenWithOptions(dir, Options{})
+	assert.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, sorted(want), values(t, db))
+
+	// Assert that a full compaction leaves a single run
+	assert.NoError(t, db.Compact())
+	assert.Len(t, runFiles(t, dir), 1)
+	assert.Equal(t, sorted(want), values(t, db))
+	for v := range want {
+		found, err := db.Search(v)
+		assert.NoError(t, err)
+		assert.True(t, found)
+	}
+}
+
+func TestDB_CompactWhileClosing(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		db, err := OpenWithOptions(dir, Options{MemtableSize: 500})
+		assert.NoError(t, err)
+
+		var want []string
+		for j := 0; j < 5000; j++ {
+			v := fmt.Sprintf("%04d", j)
+			assert.NoError(t, db.Insert(v))
+			want = append(want, v)
+		}
+
+		done := make(chan error)
+		go func() {
+			done <- db.Compact()
+		}()
+		time.Sleep(time.Duration(i) * time.Millisecond)
+		assert.NoError(t, db.Close())
+
+		// the compaction either finished before the close or was discarded
+		err = <-done
+		assert.True(t, err == nil || err == ErrClosed)
+
+		db, err = Open(dir)
+		assert.NoError(t, err)
+		assert.Equal(t, want, values(t, db))
+		assert.NoError(t, db.Close())
+	}
+}
+
+func TestDB_CloseDoesNotCompact(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	db, err := OpenWithOptions(dir, Options{CompactAt: 2})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Insert("a"))
+	assert.NoError(t, db.Flush())
+	assert.NoError(t, db.Insert("b"))
+
+	// Assert that the final flush of Close, which reaches CompactAt
+	// runs, writes its run without starting a compaction
+	next := db.nextID
+	assert.NoError(t, db.Close())
+	assert.Equal(t, next+1, db.nextID)
+	assert.Len(t, runFiles(t, dir), 2)
+
+	db, err = Open(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, values(t, db))
+
+	// Assert that a compaction waiting to start when the DB is
+	// closed returns without merging the runs
+	db.compactMu.Lock()
+	compacted := make(chan error)
+	go func() {
+		compacted <- db.Compact()
+	}()
+	closed := make(chan error)
+	go func() {
+		closed <- db.Close()
+	}()
+	for {
+		db.mu.RLock()
+		done := db.closed
+		db.mu.RUnlock()
+		if done {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	next = db.nextID
+	db.compactMu.Unlock()
+	assert.Equal(t, ErrClosed, <-compacted)
+	assert.NoError(t, <-closed)
+	assert.Equal(t, next, db.nextID)
+	assert.Len(t, runFiles(t, dir), 2)
+}
+
+func TestOpen_StrayRuns(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	db, err := Open(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Insert("a"))
+	assert.NoError(t, db.Close())
+
+	// Simulate a crash before a flushed run was added to the manifest
+	stray := filepath.Join(dir, runName(99))
+	assert.NoError(t, ioutil.WriteFile(stray, []byte("partial"), 0644))
+
+	db, err = Open(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, values(t, db))
+	assert.NoError(t, db.Close())
+
+	_, err = os.Stat(stray)
+	assert.True(t, os.IsNotExist(err))
+}
+
+// values returns the values of the passed DB in ascending order.
+func values(t *testing.T, db *DB) []string {
+	var values []string
+	assert.NoError(t, db.Iterate(func(value string) bool {
+		values = append(values, value)
+		return true
+	}))
+	return values
+}
+
+// sorted returns the keys of the passed set in ascending order.
+func sorted(set map[string]bool) []string {
+	var values []string
+	for v := range set {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// runFiles returns the names of the run files in the passed directory.
+func runFiles(t *testing.T, dir string) []string {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+runExt))
+	assert.NoError(t, err)
+	return matches
+}
+
+// tempDir returns a new temporary directory for a DB.
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "lsm")
+	assert.NoError(t, err)
+	return dir
+}
@@ -0,0 +1,129 @@
+package lsm
+
+import "container/heap"
+
+// iterator iterates over entries in ascending order of value.
+type iterator interface {
+	// next returns the next entry, and false if there are no more
+	// entries or an error occurred.
+	next() (entry, bool)
+
+	// error returns the error that stopped the iteration, if any.
+	error() error
+}
+
+// sliceIterator iterates over a sorted slice of entries.
+type sliceIterator struct {
+	entries []entry
+}
+
+func (it *sliceIterator) next() (entry, bool) {
+	if len(it.entries) == 0 {
+		return entry{}, false
+	}
+
+	e := it.entries[0]
+	it.entries = it.entries[1:]
+	return e, true
+}
+
+func (it *sliceIterator) error() error {
+	return nil
+}
+
+// mergeIterator merges iterators into a single iterator in ascending
+// order of value. When several iterators have an entry for the same
+// value, only the entry of the newest iterator is returned.
+type mergeIterator struct {
+	heap  mergeHeap
+	its   []iterator
+	err   error
+	ready bool
+}
+
+// newMergeIterator returns an iterator that merges the passed
+// iterators, which are ordered from newest to oldest.
+func newMergeIterator(its ...iterator) *mergeIterator {
+	return &mergeIterator{
+		its: its,
+	}
+}
+
+func (m *mergeIterator) next() (entry, bool) {
+	if !m.ready {
+		m.ready = true
+		for i := range m.its {
+			m.advance(i)
+		}
+	}
+
+	if m.err != nil || len(m.heap) == 0 {
+		return entry{}, false
+	}
+
+	// the newest entry for the least value is on top of the heap,
+	// and the older entries for the same value are skipped
+	top := m.heap[0]
+	for len(m.heap) > 0 && m.heap[0].entry.value == top.entry.value {
+		m.advance(heap.Pop(&m.heap).(mergeItem).source)
+	}
+
+	return top.entry, m.err == nil
+}
+
+func (m *mergeIterator) error() error {
+	return m.err
+}
+
+// advance pushes the next entry of the passed iterator onto the heap,
+// if it has one.
+func (m *mergeIterator) advance(i int) {
+	e, ok := m.its[i].next()
+	if !ok {
+		if err := m.its[i].error(); err != nil && m.err == nil {
+			m.err = err
+		}
+		return
+	}
+
+	heap.Push(&m.heap, mergeItem{
+		entry:  e,
+		source: i,
+	})
+}
+
+// mergeItem is the current entry of an iterator being merged.
+type mergeItem struct {
+	entry  entry
+	source int
+}
+
+// mergeHeap is a min-heap of the current entries of the iterators being
+// merged, ordered by value and then from newest to oldest iterator.
+type mergeHeap []mergeItem
+
+func (h mergeHeap) Len() int {
+	return len(h)
+}
+
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].entry.value != h[j].entry.value {
+		return h[i].entry.value < h[j].entry.value
+	}
+	return h[i].source < h[j].source
+}
+
+func (h mergeHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *mergeHeap) Push(x interface{}) {
+	*h = append(*h, x.(mergeItem))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,403 @@
+package lsm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+)
+
+// A run is an immutable file of entries sorted by value, laid out as
+// follows with all integers encoded as unsigned varints unless noted:
+//
+//	blocks  up to blockEntries entries each, where each entry is its
+//	        flags, the length of the prefix shared with the previous
+//	        entry of the block, the length of the rest of the value,
+//	        and the rest of the value
+//	index   the number of blocks, then per block the length of its
+//	        first value, its first value, its offset, its length,
+//	        and its CRC-32 (IEEE) as 4 little endian bytes
+//	footer  runFooterSize bytes: the offset of the index and the
+//	        number of entries (8 little endian bytes each), the
+//	        CRC-32 (IEEE) of the index (4 little endian bytes),
+//	        and the magic "TRPR"
+const (
+	runMagic      = "TRPR"
+	runFooterSize = 24
+	blockEntries  = 64
+
+	// flagTombstone is set in the flags of an entry that deletes its value.
+	flagTombstone = 1 << 0
+)
+
+// errInvalidRun is returned when reading a run that is
+// malformed or fails a checksum.
+var errInvalidRun = errors.New("lsm: invalid run")
+
+// entry is a value in a run, or a tombstone that deletes the value
+// from older runs.
+type entry struct {
+	value     string
+	tombstone bool
+}
+
+// block locates a block of entries in a run.
+type block struct {
+	first  string
+	offset uint64
+	length uint64
+	crc    uint32
+}
+
+// run is an open run file.
+type run struct {
+	id    uint64
+	f     *os.File
+	index []block
+	count uint64
+}
+
+// openRun opens the run file with the passed id at the passed path
+// and reads its index.
+func openRun(id uint64, path string) (*run, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &run{
+		id: id,
+		f:  f,
+	}
+	if err := r.readIndex(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return r, nil
+}
+
+// close closes the file of the run.
+func (r *run) close() error {
+	return r.f.Close()
+}
+
+// lookup returns whether the run has an entry for the passed value,
+// and whether that entry is a tombstone.
+func (r *run) lookup(value string) (bool, bool, error) {
+	i := sort.Search(len(r.index), func(i int) bool {
+		return r.index[i].first > value
+	})
+	if i == 0 {
+		return false, false, nil
+	}
+
+	entries, err := r.readBlock(i - 1)
+	if err != nil {
+		return false, false, err
+	}
+
+	j := sort.Search(len(entries), func(j int) bool {
+		return entries[j].value >= value
+	})
+	if j == len(entries) || entries[j].value != value {
+		return false, false, nil
+	}
+
+	return true, entries[j].tombstone, nil
+}
+
+// readIndex reads the footer and index of the run.
+func (r *run) readIndex() error {
+	info, err := r.f.Stat()
+	if err != nil {
+		return err
+	}
+	size := uint64(info.Size())
+	if size < runFooterSize {
+		return fmt.Errorf("%w: file too small", errInvalidRun)
+	}
+
+	footer := make([]byte, runFooterSize)
+	if _, err := r.f.ReadAt(footer, int64(size-runFooterSize)); err != nil {
+		return err
+	}
+	if string(footer[20:]) != runMagic {
+		return fmt.Errorf("%w: bad magic", errInvalidRun)
+	}
+
+	indexOffset := binary.LittleEndian.Uint64(footer[0:8])
+	r.count = binary.LittleEndian.Uint64(footer[8:16])
+	if indexOffset > size-runFooterSize {
+		return fmt.Errorf("%w: bad index offset", errInvalidRun)
+	}
+
+	data := make([]byte, size-runFooterSize-indexOffset)
+	if _, err := r.f.ReadAt(data, int64(indexOffset)); err != nil {
+		return err
+	}
+	if crc32.ChecksumIEEE(data) != binary.LittleEndian.Uint32(footer[16:20]) {
+		return fmt.Errorf("%w: index checksum mismatch", errInvalidRun)
+	}
+
+	br := bytes.NewReader(data)
+	blocks, err := binary.ReadUvarint(br)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidRun, err)
+	}
+	for i := uint64(0); i < blocks; i++ {
+		var b block
+		if b.first, err = readString(br, ""); err != nil {
+			return fmt.Errorf("%w: %v", errInvalidRun, err)
+		}
+		if b.offset, err = binary.ReadUvarint(br); err != nil {
+			return fmt.Errorf("%w: %v", errInvalidRun, err)
+		}
+		if b.length, err = binary.ReadUvarint(br); err != nil {
+			return fmt.Errorf("%w: %v", errInvalidRun, err)
+		}
+		var crc [4]byte
+		if _, err := io.ReadFull(br, crc[:]); err != nil {
+			return fmt.Errorf("%w: %v", errInvalidRun, err)
+		}
+		b.crc = binary.LittleEndian.Uint32(crc[:])
+
+		if b.offset+b.length > indexOffset || b.offset+b.length < b.offset {
+			return fmt.Errorf("%w: bad block", errInvalidRun)
+		}
+		r.index = append(r.index, b)
+	}
+
+	return nil
+}
+
+// readBlock reads and decodes the entries of the passed block of the run.
+func (r *run) readBlock(i int) ([]entry, error) {
+	b := r.index[i]
+	data := make([]byte, b.length)
+	if _, err := r.f.ReadAt(data, int64(b.offset)); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(data) != b.crc {
+		return nil, fmt.Errorf("%w: block checksum mismatch", errInvalidRun)
+	}
+
+	var entries []entry
+	var prev string
+	br := bytes.NewReader(data)
+	for br.Len() > 0 {
+		flags, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := readString(br, prev)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidRun, err)
+		}
+		entries = append(entries, entry{
+			value:     value,
+			tombstone: flags&flagTombstone != 0,
+		})
+		prev = value
+	}
+
+	return entries, nil
+}
+
+// readString reads a string encoded as the length of the prefix that it
+// shares with the passed previous string, followed by the length of the
+// rest of the string and the rest of the string.
+func readString(br *bytes.Reader, prev string) (string, error) {
+	shared, err := binary.ReadUvarint(br)
+	if err != nil {
+		return "", err
+	}
+	rest, err := binary.ReadUvarint(br)
+	if err != nil {
+		return "", err
+	}
+	if shared > uint64(len(prev)) || rest > uint64(br.Len()) {
+		return "", io.ErrUnexpectedEOF
+	}
+
+	b := make([]byte, shared+rest)
+	copy(b, prev[:shared])
+	if _, err := io.ReadFull(br, b[shared:]); err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// runIterator iterates over the entries of a run in ascending order.
+type runIterator struct {
+	r       *run
+	block   int
+	entries []entry
+	err     error
+}
+
+// next returns the next entry of the run, and false if there
+// are no more entries or an error occurred.
+func (it *runIterator) next() (entry, bool) {
+	for len(it.entries) == 0 {
+		if it.err != nil || it.block == len(it.r.index) {
+			return entry{}, false
+		}
+
+		it.entries, it.err = it.r.readBlock(it.block)
+		it.block++
+	}
+
+	e := it.entries[0]
+	it.entries = it.entries[1:]
+	return e, true
+}
+
+// error returns the error that stopped the iteration, if any.
+func (it *runIterator) error() error {
+	return it.err
+}
+
+// runWriter writes a run file from entries added in ascending order.
+type runWriter struct {
+	f      *os.File
+	bw     *bufio.Writer
+	offset uint64
+	block  bytes.Buffer
+	first  string
+	prev   string
+	n      int
+	index  []block
+	count  uint64
+	buf    [binary.MaxVarintLen64]byte
+}
+
+// createRun creates a run file at the passed path for writing.
+func createRun(path string) (*runWriter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &runWriter{
+		f:  f,
+		bw: bufio.NewWriter(f),
+	}, nil
+}
+
+// add adds the passed entry to the run.
+func (w *runWriter) add(e entry) error {
+	if w.n == 0 {
+		w.first = e.value
+		w.prev = ""
+	}
+
+	var flags byte
+	if e.tombstone {
+		flags |= flagTombstone
+	}
+	w.block.WriteByte(flags)
+	w.writeString(&w.block, w.prev, e.value)
+	w.prev = e.value
+	w.n++
+	w.count++
+
+	if w.n == blockEntries {
+		return w.flushBlock()
+	}
+	return nil
+}
+
+// finish writes the index and footer of the run, then syncs and
+// closes its file.
+func (w *runWriter) finish() error {
+	if err := w.flushBlock(); err != nil {
+		w.f.Close()
+		return err
+	}
+
+	var index bytes.Buffer
+	index.Write(w.buf[:binary.PutUvarint(w.buf[:], uint64(len(w.index)))])
+	for _, b := range w.index {
+		w.writeString(&index, "", b.first)
+		index.Write(w.buf[:binary.PutUvarint(w.buf[:], b.offset)])
+		index.Write(w.buf[:binary.PutUvarint(w.buf[:], b.length)])
+		binary.LittleEndian.PutUint32(w.buf[:4], b.crc)
+		index.Write(w.buf[:4])
+	}
+
+	footer := make([]byte, runFooterSize)
+	binary.LittleEndian.PutUint64(footer[0:8], w.offset)
+	binary.LittleEndian.PutUint64(footer[8:16], w.count)
+	binary.LittleEndian.PutUint32(footer[16:20], crc32.ChecksumIEEE(index.Bytes()))
+	copy(footer[20:], runMagic)
+
+	if _, err := w.bw.Write(index.Bytes()); err != nil {
+		w.f.Close()
+		return err
+	}
+	if _, err := w.bw.Write(footer); err != nil {
+		w.f.Close()
+		return err
+	}
+	if err := w.bw.Flush(); err != nil {
+		w.f.Close()
+		return err
+	}
+	if err := w.f.Sync(); err != nil {
+		w.f.Close()
+		return err
+	}
+
+	return w.f.Close()
+}
+
+// abort closes and removes the partially written run file.
+func (w *runWriter) abort() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
+
+// flushBlock writes the buffered block of entries, if any.
+func (w *runWriter) flushBlock() error {
+	if w.n == 0 {
+		return nil
+	}
+
+	data := w.block.Bytes()
+	w.index = append(w.index, block{
+		first:  w.first,
+		offset: w.offset,
+		length: uint64(len(data)),
+		crc:    crc32.ChecksumIEEE(data),
+	})
+	if _, err := w.bw.Write(data); err != nil {
+		return err
+	}
+
+	w.offset += uint64(len(data))
+	w.block.Reset()
+	w.n = 0
+	return nil
+}
+
+// writeString writes the passed string to the passed buffer as the length
+// of the prefix that it shares with the passed previous string, followed
+// by the length of the rest of the string and the rest of the string.
+func (w *runWriter) writeString(b *bytes.Buffer, prev, s string) {
+	shared := 0
+	for shared < len(prev) && shared < len(s) && prev[shared] == s[shared] {
+		shared++
+	}
+
+	b.Write(w.buf[:binary.PutUvarint(w.buf[:], uint64(shared))])
+	b.Write(w.buf[:binary.PutUvarint(w.buf[:], uint64(len(s)-shared))])
+	b.WriteString(s[shared:])
+}
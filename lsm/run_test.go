@@ -0,0 +1,102 @@
+package lsm
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, runName(1))
+
+	var want []entry
+	for i := 0; i < 500; i++ {
+		want = append(want, entry{
+			value:     fmt.Sprintf("key/%05d", i*2),
+			tombstone: i%5 == 0,
+		})
+	}
+
+	w, err := createRun(path)
+	assert.NoError(t, err)
+	for _, e := range want {
+		assert.NoError(t, w.add(e))
+	}
+	assert.NoError(t, w.finish())
+
+	r, err := openRun(1, path)
+	assert.NoError(t, err)
+	defer r.close()
+	assert.Equal(t, uint64(len(want)), r.count)
+
+	var got []entry
+	it := &runIterator{r: r}
+	for e, ok := it.next(); ok; e, ok = it.next() {
+		got = append(got, e)
+	}
+	assert.NoError(t, it.error())
+	assert.Equal(t, want, got)
+
+	for i, e := range want {
+		found, tombstone, err := r.lookup(e.value)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, e.tombstone, tombstone)
+
+		found, _, err = r.lookup(fmt.Sprintf("key/%05d", i*2+1))
+		assert.NoError(t, err)
+		assert.False(t, found)
+	}
+
+	found, _, err := r.lookup("a")
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestOpenRun_Invalid(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, runName(1))
+
+	w, err := createRun(path)
+	assert.NoError(t, err)
+	assert.NoError(t, w.add(entry{value: "a"}))
+	assert.NoError(t, w.finish())
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		corrupt func(data []byte) []byte
+	}{
+		{
+			name: "truncated footer",
+			corrupt: func(data []byte) []byte {
+				return data[:len(data)-1]
+			},
+		},
+		{
+			name: "corrupted index",
+			corrupt: func(data []byte) []byte {
+				data[len(data)-runFooterSize-1] ^= 0xff
+				return data
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corrupted := tt.corrupt(append([]byte(nil), data...))
+			assert.NoError(t, ioutil.WriteFile(path, corrupted, 0644))
+
+			_, err := openRun(1, path)
+			assert.True(t, errors.Is(err, errInvalidRun))
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package treap
+
+import (
+	"path"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// Match calls fn for each value in the Treap that the given regular
+// expression matches in ascending order. Iteration stops early if fn
+// returns false. If the regular expression is anchored at the start
+// of the text, as with ^ or \A, and can be matched in a single pass,
+// only the values that start with its literal prefix are matched
+// against it. Otherwise, every value is.
+func (t *Treap) Match(re *regexp.Regexp, fn func(value string) bool) {
+	t.PrefixScan(regexpPrefix(re), func(value string) bool {
+		if !re.MatchString(value) {
+			return true
+		}
+		return fn(value)
+	})
+}
+
+// Glob calls fn for each value in the Treap that the given pattern
+// matches in ascending order, with the pattern syntax of path.Match.
+// Iteration stops early if fn returns false. Only the values that
+// start with the text of the pattern before its first wildcard are
+// matched against it.
+// Returns path.ErrBadPattern if the pattern is malformed.
+func (t *Treap) Glob(pattern string, fn func(value string) bool) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+
+	var err error
+	t.PrefixScan(globPrefix(pattern), func(value string) bool {
+		var matched bool
+		if matched, err = path.Match(pattern, value); err != nil {
+			return false
+		}
+		if !matched {
+			return true
+		}
+		return fn(value)
+	})
+	return err
+}
+
+// regexpPrefix returns the literal text that every value matched by the
+// passed regular expression starts with, which is empty unless the
+// regular expression is known to be anchored at the start of the text.
+func regexpPrefix(re *regexp.Regexp) string {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return ""
+	}
+
+	parsed = parsed.Simplify()
+	subs := []*syntax.Regexp{parsed}
+	if parsed.Op == syntax.OpConcat {
+		subs = parsed.Sub
+	}
+	if len(subs) == 0 || subs[0].Op != syntax.OpBeginText {
+		return ""
+	}
+
+	// a ^ anchors at the start of any line instead if the regular
+	// expression was compiled with regexp.CompilePOSIX, which the Regexp
+	// doesn't reveal. Its literal prefix is only found once compiled
+	// if it's anchored at the start of the text, so it's empty otherwise.
+	prefix, _ := re.LiteralPrefix()
+	return prefix
+}
+
+// globPrefix returns the text of the passed pattern
+// before its first wildcard, without escapes.
+func globPrefix(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*', '?', '[':
+			return b.String()
+		case '\\':
+			if i+1 == len(pattern) {
+				return b.String()
+			}
+			i++
+			b.WriteByte(pattern[i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,198 @@
+package treap
+
+import (
+	"path"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// matchValues are the values of the Treaps in the match tests.
+var matchValues = []string{
+	"admin",
+	"user/[x]",
+	"user/alice",
+	"user/bob",
+	"user/carol",
+	"users",
+	"x/user/dave",
+}
+
+func TestTreap_Match(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    []string
+	}{
+		{
+			name:    "anchored",
+			pattern: `^user/[ab]`,
+			want:    []string{"user/alice", "user/bob"},
+		},
+		{
+			name:    "anchored at both ends",
+			pattern: `\Auser/.*l\z`,
+			want:    []string{"user/carol"},
+		},
+		{
+			name:    "unanchored",
+			pattern: `user/`,
+			want: []string{
+				"user/[x]", "user/alice", "user/bob", "user/carol", "x/user/dave",
+			},
+		},
+		{
+			name:    "case insensitive",
+			pattern: `(?i)^USER/B`,
+			want:    []string{"user/bob"},
+		},
+		{
+			name:    "alternation",
+			pattern: `^(admin|users)$`,
+			want:    []string{"admin", "users"},
+		},
+		{
+			name:    "no matches",
+			pattern: `^nobody`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trp := NewTreap()
+			for _, v := range matchValues {
+				trp.Insert(v)
+			}
+
+			var got []string
+			trp.Match(regexp.MustCompile(tt.pattern), func(value string) bool {
+				got = append(got, value)
+				return true
+			})
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTreap_Glob(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:    "star",
+			pattern: "user/*",
+			want:    []string{"user/[x]", "user/alice", "user/bob", "user/carol"},
+		},
+		{
+			name:    "star doesn't cross separators",
+			pattern: "*/user/*",
+			want:    []string{"x/user/dave"},
+		},
+		{
+			name:    "question mark",
+			pattern: "user?",
+			want:    []string{"users"},
+		},
+		{
+			name:    "character class",
+			pattern: "user/[a-b]*",
+			want:    []string{"user/alice", "user/bob"},
+		},
+		{
+			name:    "escaped wildcard",
+			pattern: `user/\[x]`,
+			want:    []string{"user/[x]"},
+		},
+		{
+			name:    "literal",
+			pattern: "admin",
+			want:    []string{"admin"},
+		},
+		{
+			name:    "bad pattern",
+			pattern: "user/[",
+			wantErr: path.ErrBadPattern,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trp := NewTreap()
+			for _, v := range matchValues {
+				trp.Insert(v)
+			}
+
+			var got []string
+			err := trp.Glob(tt.pattern, func(value string) bool {
+				got = append(got, value)
+				return true
+			})
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTreap_Match_POSIX(t *testing.T) {
+	trp := NewTreap()
+	for _, v := range []string{"abc", "abd", "x\nabc", "xabc"} {
+		trp.Insert(v)
+	}
+
+	// Assert that a ^ that anchors at the start of a line
+	// matches values with the prefix after a newline
+	var got []string
+	trp.Match(regexp.MustCompilePOSIX(`^abc`), func(value string) bool {
+		got = append(got, value)
+		return true
+	})
+	assert.Equal(t, []string{"abc", "x\nabc"}, got)
+}
+
+func TestRegexpPrefix(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: `abc`, want: ""},
+		{pattern: `^abc`, want: "abc"},
+		{pattern: `^abc[0-9]*$`, want: "abc"},
+		{pattern: `^abc.*`, want: ""},
+		{pattern: `\Aabc[0-9]`, want: "abc"},
+		{pattern: `(?i)^abc`, want: ""},
+		{pattern: `(?m)^abc`, want: ""},
+		{pattern: `^(abc|abd)`, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			assert.Equal(t, tt.want, regexpPrefix(regexp.MustCompile(tt.pattern)))
+
+			// a ^ of POSIX syntax may anchor at the start of any line
+			if re, err := regexp.CompilePOSIX(tt.pattern); err == nil {
+				assert.Equal(t, "", regexpPrefix(re))
+			}
+		})
+	}
+}
+
+func TestGlobPrefix(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: "", want: ""},
+		{pattern: "abc", want: "abc"},
+		{pattern: "ab*c", want: "ab"},
+		{pattern: "ab?c", want: "ab"},
+		{pattern: "ab[c]", want: "ab"},
+		{pattern: `a\*b*`, want: "a*b"},
+		{pattern: `ab\`, want: "ab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			assert.Equal(t, tt.want, globPrefix(tt.pattern))
+		})
+	}
+}
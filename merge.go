@@ -0,0 +1,152 @@
+package treap
+
+import "container/heap"
+
+// MergeMode is the way a MergeIterator treats a value
+// that is in more than one Treap.
+type MergeMode uint8
+
+const (
+	// MergeDedup yields each value once, taken from the first
+	// Treap that has it.
+	MergeDedup MergeMode = iota
+
+	// MergeKeepAll yields a value once for each Treap that has it,
+	// in the order of the Treaps.
+	MergeKeepAll
+
+	// MergeSources yields each value once, along with
+	// every Treap that has it.
+	MergeSources
+)
+
+// MergeIterator iterates over the union of several Treaps in ascending
+// order without building the union. Each value takes a logarithmic time
+// in the number of Treaps. The Treaps must be in the same order.
+type MergeIterator struct {
+	mode    MergeMode
+	heap    cursorHeap
+	cursors []*cursor
+	value   string
+	sources []int
+}
+
+// MergeIter returns a MergeIterator over the given Treaps,
+// which treats values that are in more than one of them
+// in the given mode.
+//
+// The Treaps must not be mutated while the iterator is in use. To keep
+// mutating a Treap, pass a Clone of it instead, which the iterator sees
+// as the Treap was when cloned.
+func MergeIter(mode MergeMode, treaps ...*Treap) *MergeIterator {
+	it := &MergeIterator{
+		mode:    mode,
+		cursors: make([]*cursor, len(treaps)),
+	}
+
+	if len(treaps) > 0 {
+		it.heap.order = treaps[0].order
+	}
+	for i, t := range treaps {
+		it.cursors[i] = newCursor(t.root, t.order)
+		it.advance(i)
+	}
+
+	return it
+}
+
+// Next advances the iterator to the next value.
+// Returns false when there are no more values.
+func (it *MergeIterator) Next() bool {
+	if len(it.heap.items) == 0 {
+		it.value = ""
+		it.sources = it.sources[:0]
+		return false
+	}
+
+	top := heap.Pop(&it.heap).(cursorItem)
+	it.advance(top.source)
+	it.value = top.n.value
+	it.sources = append(it.sources[:0], top.source)
+	if it.mode == MergeKeepAll {
+		return true
+	}
+
+	// the other Treaps with the value are next on the heap,
+	// in the order of the Treaps
+	for len(it.heap.items) > 0 && it.heap.items[0].n.value == it.value {
+		item := heap.Pop(&it.heap).(cursorItem)
+		it.advance(item.source)
+		if it.mode == MergeSources {
+			it.sources = append(it.sources, item.source)
+		}
+	}
+
+	return true
+}
+
+// Value returns the current value.
+func (it *MergeIterator) Value() string {
+	return it.value
+}
+
+// Sources returns the indexes of the Treaps that the current value was
+// taken from, in ascending order. That is every Treap with the value in
+// MergeSources mode, and a single Treap in the other modes. The slice is
+// only valid until the next call to Next.
+func (it *MergeIterator) Sources() []int {
+	return it.sources
+}
+
+// advance pushes the next node of the cursor of the passed Treap
+// onto the heap, if it has one.
+func (it *MergeIterator) advance(source int) {
+	n, ok := it.cursors[source].next()
+	if !ok {
+		return
+	}
+
+	heap.Push(&it.heap, cursorItem{
+		n:      n,
+		source: source,
+	})
+}
+
+// cursorItem is the current node of a Treap being merged.
+type cursorItem struct {
+	n      *node
+	source int
+}
+
+// cursorHeap is a min-heap of the current nodes of the Treaps
+// being merged, ordered by value and then by Treap.
+type cursorHeap struct {
+	items []cursorItem
+	order Order
+}
+
+func (h *cursorHeap) Len() int {
+	return len(h.items)
+}
+
+func (h *cursorHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if a.n.value != b.n.value {
+		return less(h.order, a.n.value, b.n.value)
+	}
+	return a.source < b.source
+}
+
+func (h *cursorHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+}
+
+func (h *cursorHeap) Push(x interface{}) {
+	h.items = append(h.items, x.(cursorItem))
+}
+
+func (h *cursorHeap) Pop() interface{} {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return item
+}
@@ -0,0 +1,114 @@
+package treap
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeIter(t *testing.T) {
+	treaps := [][]string{
+		{"a", "c", "e"},
+		{"b", "c", "f"},
+		{},
+		{"c", "d", "f"},
+	}
+	tests := []struct {
+		name        string
+		mode        MergeMode
+		wantValues  []string
+		wantSources [][]int
+	}{
+		{
+			name:        "dedup",
+			mode:        MergeDedup,
+			wantValues:  []string{"a", "b", "c", "d", "e", "f"},
+			wantSources: [][]int{{0}, {1}, {0}, {3}, {0}, {1}},
+		},
+		{
+			name: "keep all",
+			mode: MergeKeepAll,
+			wantValues: []string{
+				"a", "b", "c", "c", "c", "d", "e", "f", "f",
+			},
+			wantSources: [][]int{
+				{0}, {1}, {0}, {1}, {3}, {3}, {0}, {1}, {3},
+			},
+		},
+		{
+			name:        "sources",
+			mode:        MergeSources,
+			wantValues:  []string{"a", "b", "c", "d", "e", "f"},
+			wantSources: [][]int{{0}, {1}, {0, 1, 3}, {3}, {0}, {1, 3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var trps []*Treap
+			for _, values := range treaps {
+				trp := NewTreap()
+				for _, v := range values {
+					trp.Insert(v)
+				}
+				trps = append(trps, trp)
+			}
+
+			var values []string
+			var sources [][]int
+			it := MergeIter(tt.mode, trps...)
+			for it.Next() {
+				values = append(values, it.Value())
+				sources = append(sources, append([]int(nil), it.Sources()...))
+			}
+
+			assert.Equal(t, tt.wantValues, values)
+			assert.Equal(t, tt.wantSources, sources)
+			assert.False(t, it.Next())
+		})
+	}
+}
+
+func TestMergeIter_NoTreaps(t *testing.T) {
+	assert.False(t, MergeIter(MergeDedup).Next())
+}
+
+func TestMergeIter_Snapshot(t *testing.T) {
+	var trps []*Treap
+	want := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		trp := NewTreap()
+		for j := 0; j < 100; j++ {
+			v := fmt.Sprintf("%03d", (i*37+j*11)%500)
+			trp.Insert(v)
+			want[v] = true
+		}
+		trps = append(trps, trp)
+	}
+
+	// changes made to the Treaps after they're cloned aren't seen
+	clones := make([]*Treap, len(trps))
+	for i, trp := range trps {
+		clones[i] = trp.Clone()
+	}
+	it := MergeIter(MergeDedup, clones...)
+	for _, trp := range trps {
+		trp.Insert("new")
+		for _, v := range collect(trp)[:10] {
+			trp.Delete(v)
+		}
+	}
+
+	var values []string
+	for it.Next() {
+		values = append(values, it.Value())
+	}
+
+	var wantValues []string
+	for v := range want {
+		wantValues = append(wantValues, v)
+	}
+	sort.Strings(wantValues)
+	assert.Equal(t, wantValues, values)
+}
@@ -0,0 +1,327 @@
+package treap
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+)
+
+// Hash is a SHA-256 hash in a MerkleTreap.
+type Hash [sha256.Size]byte
+
+// String returns the hash as hexadecimal.
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// The domain separation prefixes of the hashes in a MerkleTreap.
+const (
+	emptyPrefix    = 0
+	nodePrefix     = 1
+	priorityPrefix = 2
+)
+
+// emptyHash is the hash of an empty tree.
+var emptyHash = Hash(sha256.Sum256([]byte{emptyPrefix}))
+
+// MerkleTreap is a Treap in which each node stores a hash of its value
+// and the hashes of its children, so that the root hash commits to the
+// whole set of values. The priority of each value is derived from a hash
+// of the value, which gives every set of values a single shape and thus
+// a single root hash, whatever the order of insertion.
+type MerkleTreap struct {
+	root *merkleNode
+}
+
+// merkleNode represents a value in a MerkleTreap.
+type merkleNode struct {
+	value    string
+	priority uint64
+	left     *merkleNode
+	right    *merkleNode
+	hash     Hash
+}
+
+// Proof proves that a value is or isn't in a MerkleTreap with a given
+// root hash. It holds the search path for the value, which passes
+// through both neighbors of a value that isn't in the MerkleTreap.
+type Proof struct {
+	// Value is the value that the proof is for.
+	Value string
+
+	// Member is true if the proof shows that Value is in the MerkleTreap,
+	// in which case Left and Right are the hashes of its children.
+	Member bool
+	Left   Hash
+	Right  Hash
+
+	// Path holds the nodes on the search path for Value, from the
+	// nearest to the root, excluding the node with Value.
+	Path []ProofStep
+}
+
+// ProofStep is a node on the search path of a Proof.
+type ProofStep struct {
+	// Value is the value of the node.
+	Value string
+
+	// Sibling is the hash of the child of the node that
+	// isn't on the search path.
+	Sibling Hash
+}
+
+// NewMerkleTreap returns a new MerkleTreap.
+func NewMerkleTreap() *MerkleTreap {
+	return &MerkleTreap{}
+}
+
+// RootHash returns the root hash of the MerkleTreap,
+// which depends only on the values in it.
+func (t *MerkleTreap) RootHash() Hash {
+	return t.root.digest()
+}
+
+// Search returns true if the given value is in the MerkleTreap.
+// Otherwise, returns false.
+func (t *MerkleTreap) Search(value string) bool {
+	for n := t.root; n != nil; {
+		if value == n.value {
+			return true
+		}
+
+		if value < n.value {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+
+	return false
+}
+
+// Iterate calls fn for each value in the MerkleTreap in ascending order.
+// Iteration stops early if fn returns false.
+func (t *MerkleTreap) Iterate(fn func(value string) bool) {
+	var stack []*merkleNode
+	for n := t.root; n != nil || len(stack) > 0; n = n.right {
+		for ; n != nil; n = n.left {
+			stack = append(stack, n)
+		}
+
+		n = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !fn(n.value) {
+			return
+		}
+	}
+}
+
+// Insert inserts the given value into the MerkleTreap.
+func (t *MerkleTreap) Insert(value string) {
+	t.root = merkleInsert(t.root, value)
+}
+
+// Delete deletes the given value from the MerkleTreap.
+func (t *MerkleTreap) Delete(value string) {
+	t.root = merkleDelete(t.root, value)
+}
+
+// ProveMember returns a Proof that the given value is in the
+// MerkleTreap, and false if it isn't.
+func (t *MerkleTreap) ProveMember(value string) (*Proof, bool) {
+	p := t.prove(value)
+	return p, p.Member
+}
+
+// ProveNonMember returns a Proof that the given value isn't in the
+// MerkleTreap, and false if it is.
+func (t *MerkleTreap) ProveNonMember(value string) (*Proof, bool) {
+	p := t.prove(value)
+	return p, !p.Member
+}
+
+// Verify returns true if the given Proof shows that its value is in the
+// MerkleTreap with the given root hash, or isn't if the Proof isn't for
+// a member. Otherwise, returns false.
+func Verify(root Hash, p *Proof) bool {
+	h := emptyHash
+	if p.Member {
+		h = nodeHash(p.Value, p.Left, p.Right)
+	}
+
+	// the search path must lead to the value, with the
+	// recomputed hashes leading to the root hash
+	for _, step := range p.Path {
+		switch {
+		case p.Value == step.Value:
+			return false
+		case p.Value < step.Value:
+			h = nodeHash(step.Value, h, step.Sibling)
+		default:
+			h = nodeHash(step.Value, step.Sibling, h)
+		}
+	}
+
+	return h == root
+}
+
+// prove returns a Proof for the given value from its search path.
+func (t *MerkleTreap) prove(value string) *Proof {
+	p := &Proof{
+		Value: value,
+	}
+
+	var path []ProofStep
+	n := t.root
+	for n != nil && n.value != value {
+		if value < n.value {
+			path = append(path, ProofStep{Value: n.value, Sibling: n.right.digest()})
+			n = n.left
+		} else {
+			path = append(path, ProofStep{Value: n.value, Sibling: n.left.digest()})
+			n = n.right
+		}
+	}
+
+	if n != nil {
+		p.Member = true
+		p.Left = n.left.digest()
+		p.Right = n.right.digest()
+	}
+
+	// the path is verified from the nearest node up to the root
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	p.Path = path
+
+	return p
+}
+
+// merkleInsert inserts a node with the passed value into the tree
+// rooted at the passed node and returns the new root.
+func merkleInsert(n *merkleNode, value string) *merkleNode {
+	if n == nil {
+		n = &merkleNode{
+			value:    value,
+			priority: hashPriority(value),
+		}
+		n.rehash()
+		return n
+	}
+
+	if value == n.value {
+		return n
+	} else if value < n.value {
+		n.left = merkleInsert(n.left, value)
+		if n.left.outranks(n) {
+			pivot := n.left
+			n.left = pivot.right
+			n.rehash()
+			pivot.right = n
+			n = pivot
+		}
+	} else {
+		n.right = merkleInsert(n.right, value)
+		if n.right.outranks(n) {
+			pivot := n.right
+			n.right = pivot.left
+			n.rehash()
+			pivot.left = n
+			n = pivot
+		}
+	}
+
+	n.rehash()
+	return n
+}
+
+// merkleDelete deletes the node with the passed value from the tree
+// rooted at the passed node and returns the new root.
+func merkleDelete(n *merkleNode, value string) *merkleNode {
+	if n == nil {
+		return nil
+	}
+
+	if value == n.value {
+		return merkleJoin(n.left, n.right)
+	} else if value < n.value {
+		n.left = merkleDelete(n.left, value)
+	} else {
+		n.right = merkleDelete(n.right, value)
+	}
+
+	n.rehash()
+	return n
+}
+
+// merkleJoin joins the trees rooted at the passed nodes, where every
+// value of the first is less than every value of the second, and
+// returns the root of the joined tree.
+func merkleJoin(l, r *merkleNode) *merkleNode {
+	if l == nil {
+		return r
+	}
+	if r == nil {
+		return l
+	}
+
+	if l.outranks(r) {
+		l.right = merkleJoin(l.right, r)
+		l.rehash()
+		return l
+	}
+
+	r.left = merkleJoin(l, r.left)
+	r.rehash()
+	return r
+}
+
+// outranks returns true if the node belongs above the passed node.
+// Ties in priority are broken by value so that the shape is unique.
+func (n *merkleNode) outranks(o *merkleNode) bool {
+	if n.priority != o.priority {
+		return n.priority > o.priority
+	}
+	return n.value < o.value
+}
+
+// rehash recomputes the hash of the node from its value and children.
+func (n *merkleNode) rehash() {
+	n.hash = nodeHash(n.value, n.left.digest(), n.right.digest())
+}
+
+// digest returns the hash of the tree rooted at the node.
+func (n *merkleNode) digest() Hash {
+	if n == nil {
+		return emptyHash
+	}
+	return n.hash
+}
+
+// nodeHash returns the hash of a node with the passed value
+// and child hashes.
+func nodeHash(value string, left, right Hash) Hash {
+	var size [binary.MaxVarintLen64]byte
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(size[:binary.PutUvarint(size[:], uint64(len(value)))])
+	h.Write([]byte(value))
+	h.Write(left[:])
+	h.Write(right[:])
+
+	var sum Hash
+	h.Sum(sum[:0])
+	return sum
+}
+
+// hashPriority returns the priority of the passed value in a MerkleTreap.
+func hashPriority(value string) uint64 {
+	h := sha256.New()
+	h.Write([]byte{priorityPrefix})
+	h.Write([]byte(value))
+
+	var sum Hash
+	h.Sum(sum[:0])
+	return binary.BigEndian.Uint64(sum[:8])
+}
@@ -0,0 +1,144 @@
+package treap
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerkleTreap_RootHash(t *testing.T) {
+	values := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+
+	first := NewMerkleTreap()
+	for _, v := range values {
+		first.Insert(v)
+	}
+
+	// Assert that the root hash depends only on the set of values
+	second := NewMerkleTreap()
+	for _, i := range rand.Perm(len(values)) {
+		second.Insert(values[i])
+	}
+	second.Insert("z")
+	assert.NotEqual(t, first.RootHash(), second.RootHash())
+	second.Delete("z")
+	assert.Equal(t, first.RootHash(), second.RootHash())
+	assert.True(t, hasMerkleTreapProperties(second.root))
+
+	var got []string
+	second.Iterate(func(value string) bool {
+		got = append(got, value)
+		return true
+	})
+	assert.Equal(t, values, got)
+
+	empty := NewMerkleTreap()
+	assert.Equal(t, emptyHash, empty.RootHash())
+	first.Iterate(func(value string) bool {
+		first.Delete(value)
+		return true
+	})
+	assert.Equal(t, emptyHash, first.RootHash())
+}
+
+func TestMerkleTreap_ProveMember(t *testing.T) {
+	trp := NewMerkleTreap()
+	inserted := fillMerkleTreap(trp, 500)
+	root := trp.RootHash()
+
+	for v := range inserted {
+		p, ok := trp.ProveMember(v)
+		assert.True(t, ok)
+		assert.True(t, Verify(root, p))
+
+		_, ok = trp.ProveNonMember(v)
+		assert.False(t, ok)
+	}
+
+	// Assert that tampered proofs fail to verify
+	for v := range inserted {
+		p, _ := trp.ProveMember(v)
+		p.Value += "x"
+		assert.False(t, Verify(root, p))
+
+		p, _ = trp.ProveMember(v)
+		p.Member = false
+		assert.False(t, Verify(root, p))
+
+		p, _ = trp.ProveMember(v)
+		if len(p.Path) > 0 {
+			p.Path[0].Sibling[0] ^= 1
+			assert.False(t, Verify(root, p))
+		}
+		break
+	}
+}
+
+func TestMerkleTreap_ProveNonMember(t *testing.T) {
+	trp := NewMerkleTreap()
+	inserted := fillMerkleTreap(trp, 500)
+	root := trp.RootHash()
+
+	values := make([]string, 0, len(inserted))
+	for v := range inserted {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+
+	for _, v := range append(values, "") {
+		absent := v + "~"
+		if inserted[absent] {
+			continue
+		}
+
+		p, ok := trp.ProveNonMember(absent)
+		assert.True(t, ok)
+		assert.True(t, Verify(root, p))
+
+		// Assert that the proof can't claim membership
+		p.Member = true
+		assert.False(t, Verify(root, p))
+	}
+
+	// Assert that a proof fails against another root hash
+	p, _ := trp.ProveNonMember("~")
+	trp.Insert("~")
+	assert.False(t, Verify(trp.RootHash(), p))
+
+	p, ok := NewMerkleTreap().ProveNonMember("a")
+	assert.True(t, ok)
+	assert.True(t, Verify(emptyHash, p))
+}
+
+// fillMerkleTreap inserts count random values into the passed
+// MerkleTreap and returns the set of values inserted.
+func fillMerkleTreap(trp *MerkleTreap, count int) map[string]bool {
+	inserted := make(map[string]bool, count)
+	for i := 0; i < count; i++ {
+		b := make([]byte, 1+rand.Intn(8))
+		for j := range b {
+			b[j] = alpha[rand.Intn(len(alpha))]
+		}
+
+		inserted[string(b)] = true
+		trp.Insert(string(b))
+	}
+
+	return inserted
+}
+
+// hasMerkleTreapProperties returns true if the passed tree has binary
+// search tree properties, heap properties, and up to date hashes.
+func hasMerkleTreapProperties(n *merkleNode) bool {
+	if n == nil {
+		return true
+	}
+
+	return (n.left == nil || (n.left.value < n.value && n.outranks(n.left))) &&
+		(n.right == nil || (n.right.value > n.value && n.outranks(n.right))) &&
+		n.hash == nodeHash(n.value, n.left.digest(), n.right.digest()) &&
+		hasMerkleTreapProperties(n.left) &&
+		hasMerkleTreapProperties(n.right)
+}
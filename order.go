@@ -0,0 +1,205 @@
+package treap
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Order is an order of the values of a Treap.
+type Order interface {
+	// Compare returns 0 if a and b are equal, -1 if a is ordered before
+	// b, and +1 if a is ordered after b. It must be a total order, in
+	// which only equal strings compare as 0.
+	Compare(a, b string) int
+}
+
+// OrderFunc is a function that compares strings in the manner of
+// Order.Compare, which can be used as an Order.
+type OrderFunc func(a, b string) int
+
+// Compare returns f(a, b).
+func (f OrderFunc) Compare(a, b string) int {
+	return f(a, b)
+}
+
+var (
+	// ByteOrder orders strings by their bytes, as the operators
+	// of Go do. It's the order of a Treap made with NewTreap.
+	ByteOrder Order = byteOrder{}
+
+	// NaturalOrder orders strings by their bytes, except that runs of
+	// decimal digits are ordered by their numeric value, so that
+	// "file2" is ordered before "file10". Strings that differ only in
+	// leading zeros are ordered by their bytes.
+	NaturalOrder Order = naturalOrder{}
+
+	// FoldOrder orders strings by their runes under Unicode simple case
+	// folding, so that "apple" is ordered before "Banana". Strings that
+	// differ only in case are ordered by their bytes.
+	FoldOrder Order = foldOrder{}
+
+	// SemverOrder orders semantic versions by precedence, as described
+	// in Semantic Versioning 2.0.0, so that "1.9.0" is ordered before
+	// "1.10.0" and a pre-release is ordered before its release. The
+	// versions may start with "v" and may leave out the minor and patch
+	// numbers, which are then 0. Versions with the same precedence are
+	// ordered by their bytes, and strings that aren't versions are
+	// ordered by their bytes after all versions.
+	SemverOrder Order = semverOrder{}
+)
+
+// NewOrderedTreap returns a new Treap whose values are in the given
+// order rather than in byte order. The prefix queries of the Treap, such
+// as PrefixScan and List, work in any order, but seek past values only
+// in byte order and otherwise walk every value.
+func NewOrderedTreap(order Order) *Treap {
+	t := NewTreap()
+	if order != ByteOrder {
+		t.order = order
+	}
+	return t
+}
+
+// Order returns the order of the values of the Treap.
+func (t *Treap) Order() Order {
+	if t.order == nil {
+		return ByteOrder
+	}
+	return t.order
+}
+
+// less returns true if the first passed value is ordered before the
+// second in the passed order, or in byte order if the order is nil.
+func less(order Order, a, b string) bool {
+	if order == nil {
+		return a < b
+	}
+	return order.Compare(a, b) < 0
+}
+
+// byteOrder is the order of ByteOrder.
+type byteOrder struct{}
+
+func (byteOrder) Compare(a, b string) int {
+	return strings.Compare(a, b)
+}
+
+// naturalOrder is the order of NaturalOrder.
+type naturalOrder struct{}
+
+func (naturalOrder) Compare(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if !isDigit(a[i]) || !isDigit(b[j]) {
+			if a[i] != b[j] {
+				return compareBytes(a[i], b[j])
+			}
+			i++
+			j++
+			continue
+		}
+
+		// runs of digits are compared by their numeric value, which is
+		// the longer run once leading zeros are skipped, or else the
+		// run with the greater digits
+		ri, rj := digitRun(a, i), digitRun(b, j)
+		na := strings.TrimLeft(a[i:ri], "0")
+		nb := strings.TrimLeft(b[j:rj], "0")
+		if len(na) != len(nb) {
+			return compareInts(len(na), len(nb))
+		}
+		if c := strings.Compare(na, nb); c != 0 {
+			return c
+		}
+		i, j = ri, rj
+	}
+
+	if len(a)-i != len(b)-j {
+		return compareInts(len(a)-i, len(b)-j)
+	}
+	return strings.Compare(a, b)
+}
+
+// foldOrder is the order of FoldOrder.
+type foldOrder struct{}
+
+func (foldOrder) Compare(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		ra, sa := rune(a[i]), 1
+		if ra >= utf8.RuneSelf {
+			ra, sa = utf8.DecodeRuneInString(a[i:])
+		}
+		rb, sb := rune(b[j]), 1
+		if rb >= utf8.RuneSelf {
+			rb, sb = utf8.DecodeRuneInString(b[j:])
+		}
+
+		if ra != rb {
+			if fa, fb := foldRune(ra), foldRune(rb); fa != fb {
+				return compareInts(int(fa), int(fb))
+			}
+		}
+		i += sa
+		j += sb
+	}
+
+	if len(a)-i != len(b)-j {
+		return compareInts(len(a)-i, len(b)-j)
+	}
+	return strings.Compare(a, b)
+}
+
+// foldRune returns the least rune that the passed rune is
+// equivalent to under Unicode simple case folding.
+func foldRune(r rune) rune {
+	if r < utf8.RuneSelf {
+		// the least rune equivalent to an ASCII letter is upper case,
+		// even for k and s, which are also equivalent to the Kelvin
+		// sign and long s
+		if 'a' <= r && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		return r
+	}
+
+	least := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < least {
+			least = f
+		}
+	}
+	return least
+}
+
+// isDigit returns true if the passed byte is a decimal digit.
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
+
+// digitRun returns the index after the run of digits
+// that starts at the passed index of the passed string.
+func digitRun(s string, i int) int {
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return i
+}
+
+// compareBytes compares the passed bytes in the manner of Order.Compare.
+func compareBytes(a, b byte) int {
+	return compareInts(int(a), int(b))
+}
+
+// compareInts compares the passed ints in the manner of Order.Compare.
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
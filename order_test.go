@@ -0,0 +1,284 @@
+package treap
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNaturalOrder(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{a: "file2", b: "file10", want: -1},
+		{a: "file10", b: "file2", want: 1},
+		{a: "file10", b: "file10", want: 0},
+		{a: "file1", b: "file01", want: 1},
+		{a: "file", b: "file1", want: -1},
+		{a: "a2b", b: "a2c", want: -1},
+		{a: "a10b2", b: "a10b10", want: -1},
+		{a: "2", b: "a", want: -1},
+		{a: "99999999999999999999999", b: "100000000000000000000000", want: -1},
+		{a: "", b: "", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.want, NaturalOrder.Compare(tt.a, tt.b))
+		})
+	}
+}
+
+func TestFoldOrder(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{a: "apple", b: "Banana", want: -1},
+		{a: "Apple", b: "apple", want: -1},
+		{a: "apple", b: "APPLE", want: 1},
+		{a: "apple", b: "apple", want: 0},
+		{a: "app", b: "APPLE", want: -1},
+		{a: "straße", b: "STRASSE", want: 1},
+		{a: "K", b: "k", want: 1},
+		{a: "Kb", b: "ka", want: 1},
+		{a: "Σ", b: "σ", want: -1},
+		{a: "ς", b: "Τ", want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.want, FoldOrder.Compare(tt.a, tt.b))
+		})
+	}
+}
+
+func TestOrders_Total(t *testing.T) {
+	values := []string{
+		"", "a", "A", "b", "B", "file1", "file01", "file10", "File2",
+		"1.0.0", "v1.0.0", "1.0.0-rc.1", "1.0.0+build", "1.2", "1.10.0",
+		"k", "K", "K", "straße", "STRASSE", "ß", "\xff", "x9y",
+	}
+	for name, order := range map[string]Order{
+		"byte":    ByteOrder,
+		"natural": NaturalOrder,
+		"fold":    FoldOrder,
+		"semver":  SemverOrder,
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, a := range values {
+				for _, b := range values {
+					c := order.Compare(a, b)
+					assert.Equal(t, -c, order.Compare(b, a))
+					assert.Equal(t, a == b, c == 0)
+					for _, d := range values {
+						if c < 0 && order.Compare(b, d) < 0 {
+							assert.True(t, order.Compare(a, d) < 0)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestNewOrderedTreap(t *testing.T) {
+	tests := []struct {
+		name   string
+		order  Order
+		values []string
+		want   []string
+	}{
+		{
+			name:   "byte order",
+			order:  ByteOrder,
+			values: []string{"file10", "file2", "File1"},
+			want:   []string{"File1", "file10", "file2"},
+		},
+		{
+			name:   "natural order",
+			order:  NaturalOrder,
+			values: []string{"file10", "file2", "file1", "file20"},
+			want:   []string{"file1", "file2", "file10", "file20"},
+		},
+		{
+			name:   "fold order",
+			order:  FoldOrder,
+			values: []string{"banana", "Apple", "apple", "Cherry"},
+			want:   []string{"Apple", "apple", "banana", "Cherry"},
+		},
+		{
+			name:   "custom order",
+			order:  OrderFunc(func(a, b string) int { return -ByteOrder.Compare(a, b) }),
+			values: []string{"a", "c", "b"},
+			want:   []string{"c", "b", "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trp := NewOrderedTreap(tt.order)
+			for _, v := range tt.values {
+				trp.Insert(v)
+			}
+			for _, v := range tt.values {
+				trp.Insert(v)
+			}
+
+			assert.Equal(t, tt.want, collect(trp))
+			assert.True(t, hasOrderedTreapProperties(trp.root, tt.order))
+			for _, v := range tt.values {
+				assert.True(t, trp.Search(v))
+			}
+
+			for _, v := range tt.values {
+				trp.Delete(v)
+				assert.False(t, trp.Search(v))
+				assert.True(t, hasOrderedTreapProperties(trp.root, tt.order))
+			}
+			assert.Nil(t, collect(trp))
+		})
+	}
+}
+
+func TestTreap_Order(t *testing.T) {
+	assert.Equal(t, ByteOrder, NewTreap().Order())
+	assert.Equal(t, ByteOrder, NewOrderedTreap(ByteOrder).Order())
+	assert.Equal(t, NaturalOrder, NewOrderedTreap(NaturalOrder).Order())
+	assert.Equal(t, NaturalOrder, NewOrderedTreap(NaturalOrder).Clone().Order())
+}
+
+func TestOrderedTreap_Queries(t *testing.T) {
+	trp := NewOrderedTreap(NaturalOrder)
+	values := []string{
+		"img/10.png", "img/2.png", "img/1.png", "doc/a", "doc/b", "readme",
+	}
+	for _, v := range values {
+		trp.Insert(v)
+	}
+
+	var got []string
+	trp.Range("img/2.png", "img/99.png", func(value string) bool {
+		got = append(got, value)
+		return true
+	})
+	assert.Equal(t, []string{"img/2.png", "img/10.png"}, got)
+
+	ceiling, ok := trp.Ceiling("img/3.png")
+	assert.True(t, ok)
+	assert.Equal(t, "img/10.png", ceiling)
+
+	got = nil
+	trp.PrefixScan("img/", func(value string) bool {
+		got = append(got, value)
+		return true
+	})
+	assert.Equal(t, []string{"img/1.png", "img/2.png", "img/10.png"}, got)
+	assert.Equal(t, 2, trp.CountPrefix("doc/"))
+
+	// listings are in byte order whatever the order of the Treap
+	list := trp.List("", "/", "", 0)
+	assert.Equal(t, []string{"readme"}, list.Keys)
+	assert.Equal(t, []string{"doc/", "img/"}, list.CommonPrefixes)
+	list = trp.List("img/", "/", "", 2)
+	assert.Equal(t, []string{"img/1.png", "img/10.png"}, list.Keys)
+	assert.True(t, list.IsTruncated)
+
+	prefix, ok := trp.ShortestUniquePrefix("img/10.png")
+	assert.True(t, ok)
+	assert.Equal(t, "img/10", prefix)
+	resolved, found, _ := trp.ResolvePrefix("img/2")
+	assert.True(t, found)
+	assert.Equal(t, "img/2.png", resolved)
+
+	var fuzzy []string
+	trp.Fuzzy("img/3.png", 1, func(value string, dist int) bool {
+		fuzzy = append(fuzzy, value)
+		return true
+	})
+	assert.Equal(t, []string{"img/1.png", "img/2.png"}, fuzzy)
+
+	assert.Equal(t, 3, trp.DeletePrefix("img/"))
+	assert.Equal(t, []string{"doc/a", "doc/b", "readme"}, collect(trp))
+	assert.True(t, hasOrderedTreapProperties(trp.root, NaturalOrder))
+}
+
+func TestOrderedTreap_Snapshot(t *testing.T) {
+	trp := NewOrderedTreap(NaturalOrder)
+	for _, v := range rand.Perm(100) {
+		trp.Insert(string(rune('a'+v%26)) + string(rune('0'+v/26)))
+	}
+	for i := 0; i < 100; i++ {
+		trp.Insert("n" + string(rune('0'+i%10)) + string(rune('0'+i/10)))
+	}
+
+	var buf bytes.Buffer
+	_, err := trp.WriteTo(&buf)
+	assert.NoError(t, err)
+	data := buf.Bytes()
+
+	read := NewOrderedTreap(NaturalOrder)
+	_, err = read.ReadFrom(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.True(t, sameShape(trp.root, read.root))
+
+	// the values of the snapshot are out of order in byte order
+	_, err = NewTreap().ReadFrom(bytes.NewReader(data))
+	assert.True(t, errors.Is(err, ErrInvalidSnapshot))
+}
+
+func TestOrderedTreap_WriteFrozen(t *testing.T) {
+	trp := NewOrderedTreap(FoldOrder)
+	values := []string{"b", "A", "a", "C", "c", "B"}
+	for _, v := range values {
+		trp.Insert(v)
+	}
+
+	path := writeFrozen(t, trp)
+	defer os.Remove(path)
+
+	f, err := OpenFrozen(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	assert.NoError(t, f.Verify())
+	for _, v := range values {
+		assert.True(t, f.Search(v))
+	}
+
+	var got []string
+	f.Iterate(func(value string) bool {
+		got = append(got, value)
+		return true
+	})
+	sort.Strings(values)
+	assert.Equal(t, values, got)
+}
+
+// hasOrderedTreapProperties returns true if the tree rooted at the passed
+// node is a treap whose values are in the passed order.
+func hasOrderedTreapProperties(root *node, order Order) bool {
+	ordered := true
+	var prev *node
+	inorder(root, func(n *node) bool {
+		if prev != nil && order.Compare(prev.value, n.value) >= 0 {
+			ordered = false
+		}
+		prev = n
+		return ordered
+	})
+
+	heap := true
+	preorder(root, func(n *node) {
+		if (n.left != nil && n.left.priority > n.priority) ||
+			(n.right != nil && n.right.priority > n.priority) {
+			heap = false
+		}
+	})
+
+	return ordered && heap
+}
@@ -0,0 +1,180 @@
+package treap
+
+import (
+	"sort"
+	"strings"
+)
+
+// PrefixScan calls fn for each value in the Treap that starts with the
+// given prefix in ascending order. Iteration stops early if fn returns
+// false. In byte order, finding the first value takes a logarithmic
+// time, after which each value takes a constant time on average. In
+// other orders, the values with the prefix needn't follow each other,
+// so every value is walked.
+func (t *Treap) PrefixScan(prefix string, fn func(value string) bool) {
+	if t.order != nil {
+		t.Iterate(func(value string) bool {
+			return !strings.HasPrefix(value, prefix) || fn(value)
+		})
+		return
+	}
+
+	if high, ok := prefixEnd(prefix); ok {
+		t.Range(prefix, high, fn)
+	} else {
+		t.IterateFrom(prefix, fn)
+	}
+}
+
+// CountPrefix returns the number of values in the Treap that
+// start with the given prefix.
+func (t *Treap) CountPrefix(prefix string) int {
+	count := 0
+	t.PrefixScan(prefix, func(string) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// HasPrefix returns true if a value in the Treap starts with the given
+// prefix. Otherwise, returns false.
+func (t *Treap) HasPrefix(prefix string) bool {
+	found := false
+	t.PrefixScan(prefix, func(string) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+// DeletePrefix deletes every value in the Treap that starts with the
+// given prefix. In byte order, the values are split off and the rest
+// are joined. In other orders, the values are deleted one by one.
+// Each deleted value is a mutation of its own in the history of the
+// Treap and in its subscriptions, but the deletion is a single version.
+// Returns the number of values deleted.
+func (t *Treap) DeletePrefix(prefix string) int {
+	if !t.HasPrefix(prefix) {
+		return 0
+	}
+	if t.order != nil {
+		return t.deleteEach(prefix)
+	}
+
+	l, r := split(t.root, prefix, t.owner, nil)
+	var deleted *node
+	if high, ok := prefixEnd(prefix); ok {
+		deleted, r = split(r, high, t.owner, nil)
+	} else {
+		deleted, r = r, nil
+	}
+
+	var ops []operation
+	inorder(deleted, func(n *node) bool {
+		ops = append(ops, operation{insert: false, value: n.value})
+		return true
+	})
+
+	t.root = join(l, r, t.owner)
+	t.commit(ops...)
+	return len(ops)
+}
+
+// deleteEach deletes every value in the Treap that starts with the
+// passed prefix one by one, as a single version.
+// Returns the number of values deleted.
+func (t *Treap) deleteEach(prefix string) int {
+	var ops []operation
+	t.PrefixScan(prefix, func(value string) bool {
+		ops = append(ops, operation{insert: false, value: value})
+		return true
+	})
+
+	for _, op := range ops {
+		t.root = delete(t.root, op.value, t.owner, t.order)
+	}
+	t.commit(ops...)
+	return len(ops)
+}
+
+// byteView is a view of the values of a Treap in byte order.
+type byteView interface {
+	ceiling(value string) (string, bool)
+	predecessor(value string) (string, bool)
+	successor(value string) (string, bool)
+}
+
+// byteView returns a view of the values of the Treap that start with the
+// passed prefix in byte order. Unless the Treap is in byte order, the
+// view holds a sorted copy of the values.
+func (t *Treap) byteView(prefix string) byteView {
+	if t.order == nil {
+		return treapView{t}
+	}
+
+	var values sortedValues
+	t.PrefixScan(prefix, func(value string) bool {
+		values = append(values, value)
+		return true
+	})
+	sort.Strings(values)
+	return values
+}
+
+// treapView is a view of the values of a Treap in byte order.
+type treapView struct {
+	t *Treap
+}
+
+func (v treapView) ceiling(value string) (string, bool) {
+	return v.t.Ceiling(value)
+}
+
+func (v treapView) predecessor(value string) (string, bool) {
+	return v.t.Predecessor(value)
+}
+
+func (v treapView) successor(value string) (string, bool) {
+	return v.t.Successor(value)
+}
+
+// sortedValues is a view of values sorted in byte order.
+type sortedValues []string
+
+func (s sortedValues) ceiling(value string) (string, bool) {
+	if i := sort.SearchStrings(s, value); i < len(s) {
+		return s[i], true
+	}
+	return "", false
+}
+
+func (s sortedValues) predecessor(value string) (string, bool) {
+	if i := sort.SearchStrings(s, value); i > 0 {
+		return s[i-1], true
+	}
+	return "", false
+}
+
+func (s sortedValues) successor(value string) (string, bool) {
+	i := sort.SearchStrings(s, value)
+	if i < len(s) && s[i] == value {
+		i++
+	}
+	if i < len(s) {
+		return s[i], true
+	}
+	return "", false
+}
+
+// prefixEnd returns the least string that is greater than every string
+// that starts with the passed prefix, and false if there is no such
+// string because the prefix is empty or made of only 0xff bytes.
+func prefixEnd(prefix string) (string, bool) {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			return prefix[:i] + string([]byte{prefix[i] + 1}), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,181 @@
+package treap
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// prefixValues are the values of the Treaps in the prefix tests.
+var prefixValues = []string{
+	"tenant/1/a",
+	"tenant/12/a",
+	"tenant/123/a",
+	"tenant/123/b",
+	"tenant/124/a",
+	"tenant/2/a",
+	"user/1",
+	"\xff",
+	"\xff\xff/a",
+}
+
+func TestTreap_PrefixScan(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		want   []string
+	}{
+		{
+			name:   "empty prefix",
+			prefix: "",
+			want:   prefixValues,
+		},
+		{
+			name:   "namespace",
+			prefix: "tenant/123/",
+			want:   []string{"tenant/123/a", "tenant/123/b"},
+		},
+		{
+			name:   "partial segment",
+			prefix: "tenant/12",
+			want:   []string{"tenant/12/a", "tenant/123/a", "tenant/123/b", "tenant/124/a"},
+		},
+		{
+			name:   "whole value",
+			prefix: "user/1",
+			want:   []string{"user/1"},
+		},
+		{
+			name:   "no values",
+			prefix: "tenant/3",
+		},
+		{
+			name:   "prefix of 0xff bytes",
+			prefix: "\xff",
+			want:   []string{"\xff", "\xff\xff/a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trp := NewTreap()
+			for _, v := range prefixValues {
+				trp.Insert(v)
+			}
+
+			var got []string
+			trp.PrefixScan(tt.prefix, func(value string) bool {
+				got = append(got, value)
+				return true
+			})
+
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, len(tt.want), trp.CountPrefix(tt.prefix))
+			assert.Equal(t, len(tt.want) > 0, trp.HasPrefix(tt.prefix))
+		})
+	}
+}
+
+func TestTreap_DeletePrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		want   int
+	}{
+		{
+			name:   "empty prefix",
+			prefix: "",
+			want:   len(prefixValues),
+		},
+		{
+			name:   "namespace",
+			prefix: "tenant/123/",
+			want:   2,
+		},
+		{
+			name:   "partial segment",
+			prefix: "tenant/12",
+			want:   4,
+		},
+		{
+			name:   "no values",
+			prefix: "tenant/3",
+		},
+		{
+			name:   "prefix of 0xff bytes",
+			prefix: "\xff",
+			want:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trp := NewTreap()
+			trp.EnableHistory(0)
+			for _, v := range prefixValues {
+				trp.Insert(v)
+			}
+			version := trp.Version()
+			s := trp.Subscribe()
+
+			assert.Equal(t, tt.want, trp.DeletePrefix(tt.prefix))
+			assert.True(t, hasTreapProperties(trp.root))
+			assert.False(t, trp.HasPrefix(tt.prefix))
+			assert.Equal(t, len(prefixValues)-tt.want, len(collect(trp)))
+
+			// the deletion is a single version, but each deleted
+			// value is a change and can be undone
+			if tt.want > 0 {
+				assert.Equal(t, version+1, trp.Version())
+			} else {
+				assert.Equal(t, version, trp.Version())
+			}
+			s.Close()
+			changes := 0
+			for c, ok := s.Next(); ok; c, ok = s.Next() {
+				assert.Equal(t, Deleted, c.Kind)
+				changes++
+			}
+			assert.Equal(t, tt.want, changes)
+
+			for i := 0; i < tt.want; i++ {
+				assert.True(t, trp.Undo())
+			}
+			assert.Equal(t, prefixValues, collect(trp))
+		})
+	}
+}
+
+func TestTreap_DeletePrefix_Clone(t *testing.T) {
+	trp := NewTreap()
+	for i := 0; i < 1000; i++ {
+		trp.Insert(fmt.Sprintf("tenant/%d/%d", i%10, i))
+	}
+
+	c := trp.Clone()
+	assert.Equal(t, 100, c.DeletePrefix("tenant/3/"))
+	assert.Equal(t, 900, len(collect(c)))
+	assert.Equal(t, 100, trp.CountPrefix("tenant/3/"))
+	assert.Equal(t, 1000, len(collect(trp)))
+}
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+		wantOk bool
+	}{
+		{prefix: "", wantOk: false},
+		{prefix: "a", want: "b", wantOk: true},
+		{prefix: "a/", want: "a0", wantOk: true},
+		{prefix: "a\xff", want: "b", wantOk: true},
+		{prefix: "a\x7f", want: "a\x80", wantOk: true},
+		{prefix: "\xff\xff", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			got, ok := prefixEnd(tt.prefix)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOk, ok)
+		})
+	}
+}
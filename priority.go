@@ -0,0 +1,74 @@
+package treap
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+)
+
+// NewKeyedTreap returns a new Treap whose priorities are derived from
+// a keyed hash of their values, HMAC-SHA256 with the given key, rather
+// than chosen at random. Ties in priority are broken by value, so a set
+// of values always takes the same shape in Treaps with the same key,
+// whatever the order in which the values were inserted or deleted.
+//
+// The key keeps the shape from being predictable to those who don't
+// know it, since values chosen to have descending priorities would
+// otherwise degrade the Treap into a list.
+func NewKeyedTreap(key []byte) *Treap {
+	t := NewTreap()
+	t.key = append([]byte{}, key...)
+	t.prf = newPRF(t.key)
+	return t
+}
+
+// Keyed returns true if the priorities of the Treap are
+// derived from a keyed hash of their values.
+func (t *Treap) Keyed() bool {
+	return t.prf != nil
+}
+
+// priority returns the priority for a new node with the passed value.
+func (t *Treap) priority(value string) int64 {
+	if t.prf == nil {
+		return randomPriority()
+	}
+
+	t.prf.Reset()
+	t.prf.Write([]byte(value))
+
+	var sum [sha256.Size]byte
+	t.prf.Sum(sum[:0])
+	p := binary.BigEndian.Uint64(sum[:8]) % (maxPriority - minPriority)
+	return int64(p) + minPriority
+}
+
+// rekey rebuilds the tree rooted at the passed node with the
+// priorities of the Treap, returning the root of the new tree.
+func (t *Treap) rekey(root *node) *node {
+	var rekeyed *node
+	inorder(root, func(n *node) bool {
+		rekeyed = insert(rekeyed, n.value, t.priority(n.value), t.owner, t.order)
+		return true
+	})
+	return rekeyed
+}
+
+// outranks returns true if the node belongs above the passed node.
+// Ties in priority are broken by value so that the shape is unique.
+func (n *node) outranks(o *node) bool {
+	if n.priority != o.priority {
+		return n.priority > o.priority
+	}
+	return n.value < o.value
+}
+
+// newPRF returns a new keyed hash with the passed key,
+// or nil if the passed key is nil.
+func newPRF(key []byte) hash.Hash {
+	if key == nil {
+		return nil
+	}
+	return hmac.New(sha256.New, key)
+}
@@ -0,0 +1,156 @@
+package treap
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewKeyedTreap(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   []byte
+		count int
+	}{
+		{
+			name:  "nil key",
+			key:   nil,
+			count: 100,
+		},
+		{
+			name:  "keyed treap with many values",
+			key:   []byte("secret"),
+			count: 1000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := make([]string, tt.count)
+			for i := range values {
+				values[i] = fmt.Sprintf("value-%d", i)
+			}
+
+			a := NewKeyedTreap(tt.key)
+			for _, v := range values {
+				a.Insert(v)
+			}
+
+			// insert in another order, with some values inserted
+			// and deleted along the way
+			b := NewKeyedTreap(tt.key)
+			rand.Shuffle(len(values), func(i, j int) {
+				values[i], values[j] = values[j], values[i]
+			})
+			for i, v := range values {
+				b.Insert(v)
+				b.Insert(fmt.Sprintf("extra-%d", i))
+			}
+			for i := range values {
+				b.Delete(fmt.Sprintf("extra-%d", i))
+			}
+
+			assert.True(t, a.Keyed())
+			assert.True(t, hasTreapProperties(a.root))
+			assert.True(t, hasTreapProperties(b.root))
+			assert.Equal(t, collect(a), collect(b))
+			assert.True(t, sameShape(a.root, b.root))
+		})
+	}
+}
+
+func TestNewKeyedTreap_DifferentKeys(t *testing.T) {
+	a, b := NewKeyedTreap([]byte("a")), NewKeyedTreap([]byte("b"))
+	for i := 0; i < 100; i++ {
+		a.Insert(fmt.Sprint(i))
+		b.Insert(fmt.Sprint(i))
+	}
+
+	assert.Equal(t, collect(a), collect(b))
+	assert.False(t, sameShape(a.root, b.root))
+}
+
+func TestTreap_Keyed(t *testing.T) {
+	assert.False(t, NewTreap().Keyed())
+	assert.True(t, NewKeyedTreap([]byte("key")).Keyed())
+	assert.True(t, NewKeyedTreap([]byte("key")).Clone().Keyed())
+}
+
+func TestTreap_Clone_Keyed(t *testing.T) {
+	a := NewKeyedTreap([]byte("key"))
+	b := NewKeyedTreap([]byte("key"))
+	for i := 0; i < 100; i++ {
+		a.Insert(fmt.Sprint(i))
+	}
+
+	c := a.Clone()
+	for i := 199; i >= 0; i-- {
+		c.Insert(fmt.Sprint(i))
+		b.Insert(fmt.Sprint(i))
+	}
+
+	assert.True(t, sameShape(b.root, c.root))
+}
+
+func TestTreap_ReadFrom_Keyed(t *testing.T) {
+	for _, priorities := range []bool{true, false} {
+		t.Run(fmt.Sprintf("priorities %v", priorities), func(t *testing.T) {
+			random := NewTreap()
+			keyed := NewKeyedTreap([]byte("key"))
+			for i := 0; i < 100; i++ {
+				random.Insert(fmt.Sprint(i))
+				keyed.Insert(fmt.Sprint(i))
+			}
+
+			var buf bytes.Buffer
+			_, err := random.WriteSnapshot(&buf, priorities)
+			assert.NoError(t, err)
+
+			read := NewKeyedTreap([]byte("key"))
+			_, err = read.ReadFrom(&buf)
+			assert.NoError(t, err)
+			assert.True(t, sameShape(keyed.root, read.root))
+		})
+	}
+}
+
+func TestNode_outranks(t *testing.T) {
+	tests := []struct {
+		name string
+		n    *node
+		o    *node
+		want bool
+	}{
+		{
+			name: "higher priority",
+			n:    &node{value: "b", priority: 2},
+			o:    &node{value: "a", priority: 1},
+			want: true,
+		},
+		{
+			name: "lower priority",
+			n:    &node{value: "a", priority: 1},
+			o:    &node{value: "b", priority: 2},
+			want: false,
+		},
+		{
+			name: "tie broken by lesser value",
+			n:    &node{value: "a", priority: 1},
+			o:    &node{value: "b", priority: 1},
+			want: true,
+		},
+		{
+			name: "tie broken by greater value",
+			n:    &node{value: "b", priority: 1},
+			o:    &node{value: "a", priority: 1},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.n.outranks(tt.o))
+		})
+	}
+}
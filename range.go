@@ -0,0 +1,148 @@
+package treap
+
+// Floor returns the greatest value in the Treap that is less than or
+// equal to the given value, and true if there is such a value.
+func (t *Treap) Floor(value string) (string, bool) {
+	var floor *node
+	for n := t.root; n != nil; {
+		if n.value == value {
+			return value, true
+		}
+
+		if less(t.order, n.value, value) {
+			floor = n
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+
+	if floor == nil {
+		return "", false
+	}
+	return floor.value, true
+}
+
+// Ceiling returns the least value in the Treap that is greater than or
+// equal to the given value, and true if there is such a value.
+func (t *Treap) Ceiling(value string) (string, bool) {
+	var ceiling *node
+	for n := t.root; n != nil; {
+		if n.value == value {
+			return value, true
+		}
+
+		if less(t.order, value, n.value) {
+			ceiling = n
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+
+	if ceiling == nil {
+		return "", false
+	}
+	return ceiling.value, true
+}
+
+// Predecessor returns the greatest value in the Treap that is less than
+// the given value, and true if there is such a value.
+func (t *Treap) Predecessor(value string) (string, bool) {
+	var pred *node
+	for n := t.root; n != nil; {
+		if less(t.order, n.value, value) {
+			pred = n
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+
+	if pred == nil {
+		return "", false
+	}
+	return pred.value, true
+}
+
+// Successor returns the least value in the Treap that is greater than
+// the given value, and true if there is such a value.
+func (t *Treap) Successor(value string) (string, bool) {
+	var succ *node
+	for n := t.root; n != nil; {
+		if less(t.order, value, n.value) {
+			succ = n
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+
+	if succ == nil {
+		return "", false
+	}
+	return succ.value, true
+}
+
+// IterateFrom calls fn for each value in the Treap that is greater
+// than or equal to the given value in ascending order.
+// Iteration stops early if fn returns false.
+func (t *Treap) IterateFrom(low string, fn func(value string) bool) {
+	ascendFrom(t.root, low, t.order, func(n *node) bool {
+		return fn(n.value)
+	})
+}
+
+// Range calls fn for each value in the Treap that is greater than or
+// equal to low and less than high in ascending order.
+// Iteration stops early if fn returns false.
+func (t *Treap) Range(low, high string, fn func(value string) bool) {
+	ascendFrom(t.root, low, t.order, func(n *node) bool {
+		return less(t.order, n.value, high) && fn(n.value)
+	})
+}
+
+// split splits the tree rooted at the passed node into a tree of the
+// values less than the passed value in the passed order and a tree of
+// the values greater than or equal to it, and returns their roots. Nodes
+// on the split path that are not tagged with the passed owner are copied
+// rather than mutated.
+func split(n *node, value string, owner uint64, order Order) (*node, *node) {
+	if n == nil {
+		return nil, nil
+	}
+
+	n = n.mutable(owner)
+	if less(order, n.value, value) {
+		var r *node
+		n.right, r = split(n.right, value, owner, order)
+		return n, r
+	}
+
+	var l *node
+	l, n.left = split(n.left, value, owner, order)
+	return l, n
+}
+
+// join joins the trees rooted at the passed nodes, where every value of
+// the first is less than every value of the second, and returns the root
+// of the joined tree. Nodes on the join path that are not tagged with
+// the passed owner are copied rather than mutated.
+func join(l, r *node, owner uint64) *node {
+	if l == nil {
+		return r
+	}
+	if r == nil {
+		return l
+	}
+
+	if l.outranks(r) {
+		l = l.mutable(owner)
+		l.right = join(l.right, r, owner)
+		return l
+	}
+
+	r = r.mutable(owner)
+	r.left = join(l, r.left, owner)
+	return r
+}
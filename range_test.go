@@ -0,0 +1,224 @@
+package treap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreap_Range(t *testing.T) {
+	values := []string{"a", "b", "c", "d", "e"}
+	tests := []struct {
+		name string
+		low  string
+		high string
+		stop int
+		want []string
+	}{
+		{
+			name: "all values",
+			low:  "",
+			high: "z",
+			want: []string{"a", "b", "c", "d", "e"},
+		},
+		{
+			name: "inner range",
+			low:  "b",
+			high: "d",
+			want: []string{"b", "c"},
+		},
+		{
+			name: "bounds between values",
+			low:  "bb",
+			high: "dd",
+			want: []string{"c", "d"},
+		},
+		{
+			name: "empty range",
+			low:  "c",
+			high: "c",
+		},
+		{
+			name: "stop early",
+			low:  "a",
+			high: "z",
+			stop: 2,
+			want: []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trp := NewTreap()
+			for _, v := range values {
+				trp.Insert(v)
+			}
+
+			var got []string
+			trp.Range(tt.low, tt.high, func(value string) bool {
+				got = append(got, value)
+				return tt.stop == 0 || len(got) < tt.stop
+			})
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTreap_IterateFrom(t *testing.T) {
+	trp := NewTreap()
+	for _, v := range []string{"a", "b", "c"} {
+		trp.Insert(v)
+	}
+
+	var got []string
+	trp.IterateFrom("ab", func(value string) bool {
+		got = append(got, value)
+		return true
+	})
+	assert.Equal(t, []string{"b", "c"}, got)
+}
+
+func TestSplitJoin(t *testing.T) {
+	trp := NewTreap()
+	fillTree(trp, 1000)
+	want := collect(trp)
+
+	for _, value := range []string{"", "m", "zzzzzz", want[len(want)/2]} {
+		c := trp.Clone()
+		l, r := split(c.root, value, c.owner, nil)
+		assert.True(t, hasTreapProperties(l))
+		assert.True(t, hasTreapProperties(r))
+		inorder(l, func(n *node) bool {
+			assert.True(t, n.value < value)
+			return true
+		})
+		inorder(r, func(n *node) bool {
+			assert.True(t, n.value >= value)
+			return true
+		})
+
+		c.root = join(l, r, c.owner)
+		assert.True(t, hasTreapProperties(c.root))
+		assert.Equal(t, want, collect(c))
+	}
+
+	// the split clones leave the original unchanged
+	assert.Equal(t, want, collect(trp))
+}
+
+func TestTreap_FloorCeiling(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		wantFloor   string
+		wantFloorOk bool
+		wantCeil    string
+		wantCeilOk  bool
+	}{
+		{
+			name:       "before all values",
+			value:      "a",
+			wantCeil:   "b",
+			wantCeilOk: true,
+		},
+		{
+			name:        "equal to a value",
+			value:       "d",
+			wantFloor:   "d",
+			wantFloorOk: true,
+			wantCeil:    "d",
+			wantCeilOk:  true,
+		},
+		{
+			name:        "between values",
+			value:       "c",
+			wantFloor:   "b",
+			wantFloorOk: true,
+			wantCeil:    "d",
+			wantCeilOk:  true,
+		},
+		{
+			name:        "after all values",
+			value:       "g",
+			wantFloor:   "f",
+			wantFloorOk: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trp := NewTreap()
+			for _, v := range []string{"b", "d", "f"} {
+				trp.Insert(v)
+			}
+
+			floor, ok := trp.Floor(tt.value)
+			assert.Equal(t, tt.wantFloor, floor)
+			assert.Equal(t, tt.wantFloorOk, ok)
+
+			ceiling, ok := trp.Ceiling(tt.value)
+			assert.Equal(t, tt.wantCeil, ceiling)
+			assert.Equal(t, tt.wantCeilOk, ok)
+		})
+	}
+}
+
+func TestTreap_PredecessorSuccessor(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		wantPred   string
+		wantPredOk bool
+		wantSucc   string
+		wantSuccOk bool
+	}{
+		{
+			name:       "before all values",
+			value:      "a",
+			wantSucc:   "b",
+			wantSuccOk: true,
+		},
+		{
+			name:       "least value",
+			value:      "b",
+			wantSucc:   "d",
+			wantSuccOk: true,
+		},
+		{
+			name:       "equal to a value",
+			value:      "d",
+			wantPred:   "b",
+			wantPredOk: true,
+			wantSucc:   "f",
+			wantSuccOk: true,
+		},
+		{
+			name:       "between values",
+			value:      "c",
+			wantPred:   "b",
+			wantPredOk: true,
+			wantSucc:   "d",
+			wantSuccOk: true,
+		},
+		{
+			name:       "greatest value",
+			value:      "f",
+			wantPred:   "d",
+			wantPredOk: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trp := NewTreap()
+			for _, v := range []string{"b", "d", "f"} {
+				trp.Insert(v)
+			}
+
+			pred, ok := trp.Predecessor(tt.value)
+			assert.Equal(t, tt.wantPred, pred)
+			assert.Equal(t, tt.wantPredOk, ok)
+
+			succ, ok := trp.Successor(tt.value)
+			assert.Equal(t, tt.wantSucc, succ)
+			assert.Equal(t, tt.wantSuccOk, ok)
+		})
+	}
+}
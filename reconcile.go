@@ -0,0 +1,549 @@
+package treap
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Reconciliation finds the values that two Treaps don't have in common
+// by comparing digests of the values within ranges. Ranges whose digests
+// match are settled. Ranges whose digests differ are split into smaller
+// ranges, until they're small enough for their values to be exchanged.
+// The count and digest of the values of each subtree are computed when
+// first needed and cached on the Treap, which is frozen so that the
+// cached nodes never change. The digest of a range and the bounds of its
+// splits are then found in logarithmic time, and both the work done and
+// the bytes exchanged are proportional to the number of differences
+// times the logarithm of the number of values, once the subtrees are
+// summarized.
+//
+// A message is the number of ranges in it followed by the ranges, with
+// all integers encoded as unsigned varints and strings encoded as their
+// length followed by their bytes. A range is a byte of flags, which has
+// rangeOpenLow set if the range has no lower bound and rangeOpenHigh set
+// if it has no upper bound, followed by the bounds that it has and its
+// kind. A rangeDigest range is followed by the number of values in the
+// range and the 16 byte digest of the values. A rangeValues range is
+// followed by a byte that is 1 if a reply is wanted, the number of
+// values in the range, and the values.
+//
+// A message that has no rangeDigest ranges and no rangeValues ranges that
+// want a reply is final. The peer that sends a final message, and the peer
+// that receives it, end the reconciliation. Every other message is replied
+// to with a message for the ranges that aren't settled.
+const (
+	rangeDigest = 1
+	rangeValues = 2
+
+	rangeOpenLow  = 1 << 0
+	rangeOpenHigh = 1 << 1
+
+	// reconcileSplit is the number of ranges that a range
+	// whose digests differ is split into.
+	reconcileSplit = 16
+
+	// reconcileMaxValues is the number of values in a range up to
+	// which the values are exchanged instead of splitting the range.
+	reconcileMaxValues = 2 * reconcileSplit
+)
+
+// Reconcile exchanges the values that the Treap and a peer Treap don't
+// have in common over the given connection, so that both end up with the
+// union of their values. The peer must call Reconcile with the other end
+// of the connection, and exactly one of the two must initiate. The
+// Treaps must be in the same order.
+//
+// The first reconciliation of a Treap hashes all of its values, which
+// takes linear time. Later ones reuse the summaries of the subtrees that
+// haven't changed since, so they hash only the values on the paths that
+// changed. The summaries take memory for each value of the Treap until
+// they're dropped, which happens when most of them are out of date.
+// Treaps that are never reconciled don't pay for them.
+// Returns the values that were inserted into the Treap.
+func (t *Treap) Reconcile(rw io.ReadWriter, initiate bool) ([]string, error) {
+	r := newReconciler(t, rw)
+	defer r.prune()
+	return r.run(initiate)
+}
+
+// digest is a 128 bit hash of a set of values, which is the sum of the
+// hashes of the values. Unlike a xor, a sum doesn't cancel out values
+// that appear on both sides of a comparison of digests, and the digest
+// of a subset is found by subtracting the digests of the other values.
+type digest [2]uint64
+
+// hashValue returns the digest of the set holding only the passed value.
+func hashValue(value string) digest {
+	sum := sha256.Sum256([]byte(value))
+	return digest{
+		binary.LittleEndian.Uint64(sum[0:8]),
+		binary.LittleEndian.Uint64(sum[8:16]),
+	}
+}
+
+// add returns the digest of the union of the disjoint sets of values
+// with the passed digests.
+func (d digest) add(o digest) digest {
+	return digest{d[0] + o[0], d[1] + o[1]}
+}
+
+// sub returns the digest of the values of the first set that aren't in
+// the second, which must be a subset of it.
+func (d digest) sub(o digest) digest {
+	return digest{d[0] - o[0], d[1] - o[1]}
+}
+
+// summary summarizes the subtree rooted at a frozen node with the
+// hash of its value, and the count and digest of its values.
+type summary struct {
+	hash   digest
+	count  int
+	digest digest
+}
+
+// reconcileRange is a range of values in a reconciliation message,
+// covering values greater than or equal to low and less than high.
+// The range has no lower bound if openLow is true, and no upper
+// bound if openHigh is true.
+type reconcileRange struct {
+	low      string
+	high     string
+	openLow  bool
+	openHigh bool
+	kind     byte
+
+	// count and digest summarize the values of a rangeDigest range.
+	count  uint64
+	digest digest
+
+	// values holds the values of a rangeValues range,
+	// and reply is true if the values of the peer are wanted.
+	values []string
+	reply  bool
+}
+
+// reconciler holds the state of one side of a reconciliation.
+type reconciler struct {
+	t        *Treap
+	r        *bufio.Reader
+	w        *bufio.Writer
+	inserted []string
+
+	// steps is the number of nodes visited or summarized to summarize,
+	// split and list ranges, which grows with the number of differences.
+	steps int
+}
+
+// newReconciler returns a reconciler of the passed Treap
+// that exchanges messages over the passed connection.
+func newReconciler(t *Treap, rw io.ReadWriter) *reconciler {
+	if t.summaries == nil {
+		t.summaries = make(map[*node]summary)
+	}
+
+	// the nodes are summarized once they can no longer change
+	t.freeze()
+
+	return &reconciler{
+		t: t,
+		r: bufio.NewReader(rw),
+		w: bufio.NewWriter(rw),
+	}
+}
+
+// run reconciles the Treap with the peer, sending the first
+// message if initiate is true, and returns the values inserted.
+func (r *reconciler) run(initiate bool) ([]string, error) {
+	if initiate {
+		all := reconcileRange{openLow: true, openHigh: true}
+		if err := r.send([]reconcileRange{r.summarize(all)}); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		msg, err := r.receive()
+		if err != nil {
+			return r.inserted, err
+		}
+
+		reply := r.process(msg)
+		if final(msg) {
+			return r.inserted, nil
+		}
+
+		if err := r.send(reply); err != nil {
+			return r.inserted, err
+		}
+		if final(reply) {
+			return r.inserted, nil
+		}
+	}
+}
+
+// process processes the ranges of the passed message
+// and returns the ranges of the reply.
+func (r *reconciler) process(msg []reconcileRange) []reconcileRange {
+	var reply []reconcileRange
+	for _, in := range msg {
+		switch in.kind {
+		case rangeDigest:
+			lowRank, lowDigest := r.lowBound(in)
+			highRank, highDigest := r.highBound(in)
+			count := highRank - lowRank
+			if uint64(count) == in.count && highDigest.sub(lowDigest) == in.digest {
+				continue
+			}
+
+			if count <= reconcileMaxValues {
+				out := in
+				out.kind = rangeValues
+				out.values = r.values(in)
+				out.reply = true
+				reply = append(reply, out)
+				continue
+			}
+
+			reply = append(reply, r.split(in, lowRank, lowDigest, count, highDigest)...)
+		case rangeValues:
+			theirs := make(map[string]bool, len(in.values))
+			for _, v := range in.values {
+				theirs[v] = true
+				if !r.t.Search(v) {
+					r.t.Insert(v)
+					r.inserted = append(r.inserted, v)
+				}
+			}
+			r.t.freeze()
+			if !in.reply {
+				continue
+			}
+
+			// the range holds at most the values of the peer
+			// and the values that the peer doesn't have
+			var missing []string
+			for _, v := range r.values(in) {
+				if !theirs[v] {
+					missing = append(missing, v)
+				}
+			}
+			if len(missing) > 0 {
+				out := in
+				out.values = missing
+				out.reply = false
+				reply = append(reply, out)
+			}
+		}
+	}
+
+	return reply
+}
+
+// summarize returns a rangeDigest range for the passed range.
+func (r *reconciler) summarize(rg reconcileRange) reconcileRange {
+	lowRank, lowDigest := r.lowBound(rg)
+	highRank, highDigest := r.highBound(rg)
+
+	rg.kind = rangeDigest
+	rg.count = uint64(highRank - lowRank)
+	rg.digest = highDigest.sub(lowDigest)
+	rg.values = nil
+	return rg
+}
+
+// split splits the passed range, which holds the count values from
+// the one at the passed rank onwards, into reconcileSplit rangeDigest
+// ranges of about equal numbers of values. The values before the range
+// and the values up to its end have the passed digests.
+func (r *reconciler) split(rg reconcileRange, rank int, before digest, count int, through digest) []reconcileRange {
+	var split []reconcileRange
+	sub := rg
+	start, startDigest := 0, before
+	for i := 1; i <= reconcileSplit; i++ {
+		end := i * count / reconcileSplit
+		next := rg
+		endDigest := through
+		if end < count {
+			var value string
+			value, endDigest = r.selectRank(rank + end)
+			sub.high, sub.openHigh = value, false
+			next.low, next.openLow = value, false
+		}
+
+		sub.kind = rangeDigest
+		sub.count = uint64(end - start)
+		sub.digest = endDigest.sub(startDigest)
+		split = append(split, sub)
+
+		sub = next
+		start, startDigest = end, endDigest
+	}
+
+	return split
+}
+
+// lowBound returns the number of values of the Treap that are
+// before the passed range, and the digest of those values.
+func (r *reconciler) lowBound(rg reconcileRange) (int, digest) {
+	if rg.openLow {
+		return 0, digest{}
+	}
+	return r.before(rg.low)
+}
+
+// highBound returns the number of values of the Treap that are before
+// the end of the passed range, and the digest of those values.
+func (r *reconciler) highBound(rg reconcileRange) (int, digest) {
+	if !rg.openHigh {
+		return r.before(rg.high)
+	}
+
+	s := r.summary(r.t.root)
+	return s.count, s.digest
+}
+
+// before returns the number of values of the Treap that are
+// less than the passed value, and the digest of those values.
+func (r *reconciler) before(value string) (int, digest) {
+	count, d := 0, digest{}
+	for n := r.t.root; n != nil; {
+		r.steps++
+		if !less(r.t.order, n.value, value) {
+			n = n.left
+			continue
+		}
+
+		s, left := r.summary(n), r.summary(n.left)
+		count += 1 + left.count
+		d = d.add(s.hash).add(left.digest)
+		n = n.right
+	}
+
+	return count, d
+}
+
+// selectRank returns the value of the Treap with the passed rank, which
+// must be less than the number of values, and the digest of the values
+// before it.
+func (r *reconciler) selectRank(rank int) (string, digest) {
+	d := digest{}
+	for n := r.t.root; ; {
+		r.steps++
+		left := r.summary(n.left)
+
+		switch {
+		case rank < left.count:
+			n = n.left
+		case rank == left.count:
+			return n.value, d.add(left.digest)
+		default:
+			rank -= left.count + 1
+			d = d.add(left.digest).add(r.summary(n).hash)
+			n = n.right
+		}
+	}
+}
+
+// summary returns the summary of the subtree rooted at the passed node,
+// which must be frozen, summarizing the subtrees that aren't yet cached.
+func (r *reconciler) summary(n *node) summary {
+	if n == nil {
+		return summary{}
+	}
+	if s, ok := r.t.summaries[n]; ok {
+		return s
+	}
+
+	r.steps++
+	left, right := r.summary(n.left), r.summary(n.right)
+	s := summary{hash: hashValue(n.value)}
+	s.count = 1 + left.count + right.count
+	s.digest = s.hash.add(left.digest).add(right.digest)
+	r.t.summaries[n] = s
+	return s
+}
+
+// prune drops the cached summaries of the Treap if most of them
+// are of nodes that are no longer in it.
+func (r *reconciler) prune() {
+	if len(r.t.summaries) > 2*r.summary(r.t.root).count {
+		r.t.summaries = nil
+	}
+}
+
+// values returns the values of the Treap within the passed range.
+func (r *reconciler) values(rg reconcileRange) []string {
+	var values []string
+	ascendWhere(r.t.root, func(value string) bool {
+		r.steps++
+		return rg.openLow || !less(r.t.order, value, rg.low)
+	}, func(n *node) bool {
+		r.steps++
+		if !rg.openHigh && !less(r.t.order, n.value, rg.high) {
+			return false
+		}
+
+		values = append(values, n.value)
+		return true
+	})
+	return values
+}
+
+// send writes the passed message to the peer.
+func (r *reconciler) send(msg []reconcileRange) error {
+	var buf [binary.MaxVarintLen64]byte
+	uvarint := func(x uint64) {
+		r.w.Write(buf[:binary.PutUvarint(buf[:], x)])
+	}
+	str := func(s string) {
+		uvarint(uint64(len(s)))
+		r.w.WriteString(s)
+	}
+
+	uvarint(uint64(len(msg)))
+	for _, rg := range msg {
+		var flags byte
+		if rg.openLow {
+			flags |= rangeOpenLow
+		}
+		if rg.openHigh {
+			flags |= rangeOpenHigh
+		}
+		r.w.WriteByte(flags)
+		if !rg.openLow {
+			str(rg.low)
+		}
+		if !rg.openHigh {
+			str(rg.high)
+		}
+		r.w.WriteByte(rg.kind)
+
+		switch rg.kind {
+		case rangeDigest:
+			uvarint(rg.count)
+			var d [16]byte
+			binary.LittleEndian.PutUint64(d[0:8], rg.digest[0])
+			binary.LittleEndian.PutUint64(d[8:16], rg.digest[1])
+			r.w.Write(d[:])
+		case rangeValues:
+			if rg.reply {
+				r.w.WriteByte(1)
+			} else {
+				r.w.WriteByte(0)
+			}
+			uvarint(uint64(len(rg.values)))
+			for _, v := range rg.values {
+				str(v)
+			}
+		}
+	}
+
+	return r.w.Flush()
+}
+
+// receive reads a message from the peer.
+func (r *reconciler) receive() ([]reconcileRange, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	var msg []reconcileRange
+	for i := uint64(0); i < n; i++ {
+		var rg reconcileRange
+		flags, err := r.r.ReadByte()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		rg.openLow = flags&rangeOpenLow != 0
+		rg.openHigh = flags&rangeOpenHigh != 0
+		if !rg.openLow {
+			if rg.low, err = r.readString(); err != nil {
+				return nil, err
+			}
+		}
+		if !rg.openHigh {
+			if rg.high, err = r.readString(); err != nil {
+				return nil, err
+			}
+		}
+		if rg.kind, err = r.r.ReadByte(); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+
+		switch rg.kind {
+		case rangeDigest:
+			if rg.count, err = binary.ReadUvarint(r.r); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			var d [16]byte
+			if _, err := io.ReadFull(r.r, d[:]); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			rg.digest[0] = binary.LittleEndian.Uint64(d[0:8])
+			rg.digest[1] = binary.LittleEndian.Uint64(d[8:16])
+		case rangeValues:
+			if rg.reply, err = r.readFlag(); err != nil {
+				return nil, err
+			}
+			count, err := binary.ReadUvarint(r.r)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			for j := uint64(0); j < count; j++ {
+				v, err := r.readString()
+				if err != nil {
+					return nil, err
+				}
+				rg.values = append(rg.values, v)
+			}
+		default:
+			return nil, fmt.Errorf("treap: invalid range kind %d", rg.kind)
+		}
+
+		msg = append(msg, rg)
+	}
+
+	return msg, nil
+}
+
+// readString reads a string from the peer.
+func (r *reconciler) readString() (string, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return "", unexpectedEOF(err)
+	}
+	if size > math.MaxInt64 {
+		return "", fmt.Errorf("treap: invalid string size %d", size)
+	}
+
+	var b strings.Builder
+	if _, err := io.CopyN(&b, r.r, int64(size)); err != nil {
+		return "", unexpectedEOF(err)
+	}
+	return b.String(), nil
+}
+
+// readFlag reads a flag byte from the peer.
+func (r *reconciler) readFlag() (bool, error) {
+	b, err := r.r.ReadByte()
+	if err != nil {
+		return false, unexpectedEOF(err)
+	}
+	return b == 1, nil
+}
+
+// final returns true if the passed message needs no reply.
+func final(msg []reconcileRange) bool {
+	for _, rg := range msg {
+		if rg.kind == rangeDigest || (rg.kind == rangeValues && rg.reply) {
+			return false
+		}
+	}
+	return true
+}
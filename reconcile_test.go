@@ -0,0 +1,161 @@
+package treap
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreap_Reconcile(t *testing.T) {
+	tests := []struct {
+		name        string
+		shared      int
+		onlyLeft    []string
+		onlyRight   []string
+		wantMaxSent int
+	}{
+		{
+			name: "empty treaps",
+		},
+		{
+			name:     "empty peer",
+			onlyLeft: []string{"a", "b", "c"},
+		},
+		{
+			name:        "identical treaps",
+			shared:      10000,
+			wantMaxSent: 100,
+		},
+		{
+			name:        "few differences",
+			shared:      10000,
+			onlyLeft:    []string{"left-1", "left-2"},
+			onlyRight:   []string{"right-1", "value-05000x"},
+			wantMaxSent: 20000,
+		},
+		{
+			name:      "disjoint treaps",
+			onlyLeft:  []string{"a", "c", "e"},
+			onlyRight: []string{"b", "d", "f"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, right := NewTreap(), NewTreap()
+			for i := 0; i < tt.shared; i++ {
+				v := fmt.Sprintf("value-%05d", i)
+				left.Insert(v)
+				right.Insert(v)
+			}
+			for _, v := range tt.onlyLeft {
+				left.Insert(v)
+			}
+			for _, v := range tt.onlyRight {
+				right.Insert(v)
+			}
+
+			a, b := net.Pipe()
+			counted := &countingConn{Conn: a}
+			type result struct {
+				inserted []string
+				err      error
+			}
+			done := make(chan result)
+			go func() {
+				inserted, err := right.Reconcile(b, false)
+				done <- result{inserted, err}
+			}()
+
+			leftInserted, err := left.Reconcile(counted, true)
+			assert.NoError(t, err)
+			res := <-done
+			assert.NoError(t, res.err)
+
+			sort.Strings(leftInserted)
+			sort.Strings(res.inserted)
+			assert.Equal(t, nilIfEmpty(tt.onlyRight), leftInserted)
+			assert.Equal(t, nilIfEmpty(tt.onlyLeft), res.inserted)
+			assert.Equal(t, collect(left), collect(right))
+			if tt.wantMaxSent > 0 {
+				assert.True(t, counted.n <= tt.wantMaxSent,
+					"sent %d bytes", counted.n)
+			}
+		})
+	}
+}
+
+func TestTreap_Reconcile_Work(t *testing.T) {
+	// reconcile reconciles the passed Treaps and returns the number
+	// of nodes that the initiating side visits or summarizes
+	reconcile := func(left, right *Treap) int {
+		a, b := net.Pipe()
+		done := make(chan error)
+		go func() {
+			_, err := right.Reconcile(b, false)
+			done <- err
+		}()
+
+		r := newReconciler(left, a)
+		_, err := r.run(true)
+		assert.NoError(t, err)
+		assert.NoError(t, <-done)
+		assert.Equal(t, collect(left), collect(right))
+		return r.steps
+	}
+
+	// steps returns the work of reconciling n values with a few
+	// differences on each side, after a first reconciliation
+	steps := func(n int) int {
+		left := NewKeyedTreap([]byte("key"))
+		for i := 0; i < n; i++ {
+			left.Insert(fmt.Sprintf("value-%07d", i))
+		}
+		right := left.Clone()
+
+		// Assert that the first reconciliation summarizes every value
+		assert.True(t, reconcile(left, right) >= n)
+
+		for i := 0; i < 4; i++ {
+			left.Insert(fmt.Sprintf("value-%07d-left", i*n/4))
+			right.Insert(fmt.Sprintf("value-%07d-right", i*n/4))
+		}
+		return reconcile(left, right)
+	}
+
+	// Assert that ten times the values takes far less than ten times
+	// the work, which grows with the logarithm of the number of values
+	small, large := steps(20000), steps(200000)
+	assert.True(t, large < 2*small, "%d steps, then %d steps", small, large)
+	assert.True(t, large < 200000/10, "%d steps", large)
+
+	// Assert that Treaps that aren't reconciled keep no summaries
+	trp := NewTreap()
+	fillTree(trp, 100)
+	assert.Nil(t, trp.summaries)
+}
+
+// countingConn counts the bytes written to a connection.
+type countingConn struct {
+	net.Conn
+	n int
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.n += n
+	return n, err
+}
+
+// nilIfEmpty returns the passed values sorted, or nil if there are none.
+func nilIfEmpty(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	return sorted
+}
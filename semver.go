@@ -0,0 +1,402 @@
+package treap
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidConstraint is returned when a version constraint is malformed.
+var ErrInvalidConstraint = errors.New("treap: invalid version constraint")
+
+// semverOrder is the order of SemverOrder.
+type semverOrder struct{}
+
+func (semverOrder) Compare(a, b string) int {
+	va, okA := parseVersion(a)
+	vb, okB := parseVersion(b)
+	switch {
+	case okA && okB:
+		if c := va.compare(vb); c != 0 {
+			return c
+		}
+	case okA:
+		return -1
+	case okB:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+// version is a semantic version. The numbers are held as decimal
+// strings without leading zeros, so that they're never out of range.
+type version struct {
+	major, minor, patch string
+
+	// parts is the number of the major, minor and patch
+	// numbers that were given.
+	parts int
+
+	// pre is the pre-release of the version,
+	// which is empty for a release.
+	pre string
+}
+
+// parseVersion parses the passed semantic version,
+// and returns false if it isn't one.
+func parseVersion(s string) (version, bool) {
+	var v version
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		if !validIdentifiers(s[i+1:]) {
+			return version{}, false
+		}
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		if !validIdentifiers(s[i+1:]) {
+			return version{}, false
+		}
+		s, v.pre = s[:i], s[i+1:]
+	}
+
+	numbers := []*string{&v.major, &v.minor, &v.patch}
+	for s != "" || v.parts == 0 {
+		if v.parts == len(numbers) {
+			return version{}, false
+		}
+
+		n := s
+		if i := strings.IndexByte(s, '.'); i >= 0 {
+			n, s = s[:i], s[i+1:]
+			if s == "" {
+				return version{}, false
+			}
+		} else {
+			s = ""
+		}
+		if n == "" || digitRun(n, 0) != len(n) {
+			return version{}, false
+		}
+
+		*numbers[v.parts] = trimZeros(n)
+		v.parts++
+	}
+	for _, n := range numbers[v.parts:] {
+		*n = "0"
+	}
+
+	return v, true
+}
+
+// compare compares the precedence of the version with the passed
+// version in the manner of Order.Compare.
+func (v version) compare(o version) int {
+	if c := compareNumbers(v.major, o.major); c != 0 {
+		return c
+	}
+	if c := compareNumbers(v.minor, o.minor); c != 0 {
+		return c
+	}
+	if c := compareNumbers(v.patch, o.patch); c != 0 {
+		return c
+	}
+
+	// a pre-release has a lower precedence than its release
+	switch {
+	case v.pre == o.pre:
+		return 0
+	case v.pre == "":
+		return 1
+	case o.pre == "":
+		return -1
+	}
+
+	a, b := v.pre, o.pre
+	for a != "" && b != "" {
+		var ia, ib string
+		ia, a = nextIdentifier(a)
+		ib, b = nextIdentifier(b)
+
+		// numeric identifiers have a lower precedence
+		// than alphanumeric identifiers
+		na, nb := digitRun(ia, 0) == len(ia), digitRun(ib, 0) == len(ib)
+		var c int
+		switch {
+		case na && nb:
+			c = compareNumbers(trimZeros(ia), trimZeros(ib))
+		case na:
+			c = -1
+		case nb:
+			c = 1
+		default:
+			c = strings.Compare(ia, ib)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	// a larger set of identifiers has a higher precedence
+	return compareInts(len(a), len(b))
+}
+
+// next returns the version that follows every version whose numbers
+// start with the numbers that were given for the version.
+func (v version) next() version {
+	n := version{
+		major: v.major,
+		minor: "0",
+		patch: "0",
+		parts: 3,
+	}
+
+	switch v.parts {
+	case 1:
+		n.major = incrementNumber(v.major)
+	case 2:
+		n.minor = incrementNumber(v.minor)
+	default:
+		n.minor = v.minor
+		n.patch = incrementNumber(v.patch)
+	}
+	return n
+}
+
+// nextIdentifier returns the first of the passed dot-separated
+// identifiers, and the rest of them.
+func nextIdentifier(s string) (string, string) {
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// validIdentifiers returns true if the passed string is dot-separated
+// identifiers of ASCII letters, digits and hyphens.
+func validIdentifiers(s string) bool {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		for i := 0; i < len(id); i++ {
+			c := id[i]
+			if !isDigit(c) && c != '-' &&
+				!('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// trimZeros returns the passed decimal number without leading zeros.
+func trimZeros(n string) string {
+	if n = strings.TrimLeft(n, "0"); n == "" {
+		return "0"
+	}
+	return n
+}
+
+// compareNumbers compares the passed decimal numbers without leading
+// zeros in the manner of Order.Compare.
+func compareNumbers(a, b string) int {
+	if len(a) != len(b) {
+		return compareInts(len(a), len(b))
+	}
+	return strings.Compare(a, b)
+}
+
+// incrementNumber returns the passed decimal number plus one.
+func incrementNumber(n string) string {
+	b := []byte(n)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] != '9' {
+			b[i]++
+			return string(b)
+		}
+		b[i] = '0'
+	}
+	return "1" + string(b)
+}
+
+// versionComparator is a comparison of versions with a version.
+type versionComparator struct {
+	op string
+	v  version
+}
+
+// satisfied returns true if the passed version satisfies the comparator.
+func (c versionComparator) satisfied(v version) bool {
+	cmp := v.compare(c.v)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	default:
+		return cmp == 0
+	}
+}
+
+// versionConstraint is a constraint on versions, which is satisfied
+// by a version that satisfies every comparator of any of its sets.
+type versionConstraint [][]versionComparator
+
+// parseConstraint parses the passed version constraint.
+func parseConstraint(s string) (versionConstraint, error) {
+	var constraint versionConstraint
+	for _, alt := range strings.Split(s, "||") {
+		var set []versionComparator
+		fields := strings.Fields(alt)
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+
+			op := ""
+			for _, o := range []string{">=", "<=", ">", "<", "="} {
+				if strings.HasPrefix(field, o) {
+					op = o
+					break
+				}
+			}
+
+			// an operator may be separated from its version by spaces
+			text := field[len(op):]
+			if text == "" && op != "" && i+1 < len(fields) {
+				i++
+				text = fields[i]
+			}
+
+			v, ok := parseVersion(text)
+			if !ok {
+				return nil, fmt.Errorf("%w: %q isn't a version",
+					ErrInvalidConstraint, text)
+			}
+
+			// equality with a partial version is satisfied by
+			// every version that starts with its numbers
+			if (op == "" || op == "=") && v.parts < 3 && v.pre == "" {
+				set = append(set,
+					versionComparator{op: ">=", v: v},
+					versionComparator{op: "<", v: v.next()})
+				continue
+			}
+			set = append(set, versionComparator{op: op, v: v})
+		}
+
+		if len(set) == 0 {
+			return nil, fmt.Errorf("%w: empty comparator set in %q",
+				ErrInvalidConstraint, s)
+		}
+		constraint = append(constraint, set)
+	}
+
+	return constraint, nil
+}
+
+// satisfied returns true if the passed version satisfies the constraint.
+func (vc versionConstraint) satisfied(v version) bool {
+	for _, set := range vc {
+		ok := true
+		for _, c := range set {
+			if !c.satisfied(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// bounds returns the least lower bound and the greatest upper bound
+// of the versions that satisfy the constraint, with false for a bound
+// that doesn't exist.
+func (vc versionConstraint) bounds() (low version, hasLow bool, high version, hasHigh bool) {
+	hasLow, hasHigh = true, true
+	for i, set := range vc {
+		var setLow, setHigh version
+		setHasLow, setHasHigh := false, false
+		for _, c := range set {
+			if c.op != "<" && c.op != "<=" &&
+				(!setHasLow || c.v.compare(setLow) > 0) {
+				setLow, setHasLow = c.v, true
+			}
+			if c.op != ">" && c.op != ">=" &&
+				(!setHasHigh || c.v.compare(setHigh) < 0) {
+				setHigh, setHasHigh = c.v, true
+			}
+		}
+
+		if !setHasLow {
+			hasLow = false
+		} else if hasLow && (i == 0 || setLow.compare(low) < 0) {
+			low = setLow
+		}
+		if !setHasHigh {
+			hasHigh = false
+		} else if hasHigh && (i == 0 || setHigh.compare(high) > 0) {
+			high = setHigh
+		}
+	}
+
+	return low, hasLow, high, hasHigh
+}
+
+// VersionRange calls fn for each value in the Treap that is a semantic
+// version satisfying the given constraint, in ascending order. Iteration
+// stops early if fn returns false.
+//
+// A constraint is sets of comparators separated by "||", and is satisfied
+// by a version that satisfies every comparator of any set. A comparator is
+// an operator, one of <, <=, >, >= and =, followed by a version, such as
+// ">=1.2 <2". A version without an operator is compared for equality. A
+// version compared for equality that leaves out its minor or patch number
+// is satisfied by every version that starts with the numbers it gives, so
+// "1.2" is satisfied by 1.2.0 up to but not including 1.3.0. Versions are
+// compared by precedence, as in SemverOrder.
+//
+// In a Treap in SemverOrder, only the values between the least and
+// greatest versions that could satisfy the constraint are walked.
+// Otherwise, every value is.
+// Returns ErrInvalidConstraint if the constraint is malformed.
+func (t *Treap) VersionRange(constraint string, fn func(value string) bool) error {
+	vc, err := parseConstraint(constraint)
+	if err != nil {
+		return err
+	}
+
+	if t.order != SemverOrder {
+		t.Iterate(func(value string) bool {
+			if v, ok := parseVersion(value); ok && vc.satisfied(v) {
+				return fn(value)
+			}
+			return true
+		})
+		return nil
+	}
+
+	// strings that aren't versions are ordered after every version
+	low, hasLow, high, hasHigh := vc.bounds()
+	ascendWhere(t.root, func(value string) bool {
+		v, ok := parseVersion(value)
+		return !ok || !hasLow || v.compare(low) >= 0
+	}, func(n *node) bool {
+		v, ok := parseVersion(n.value)
+		if !ok || (hasHigh && v.compare(high) > 0) {
+			return false
+		}
+		if !vc.satisfied(v) {
+			return true
+		}
+		return fn(n.value)
+	})
+	return nil
+}
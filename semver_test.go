@@ -0,0 +1,180 @@
+package treap
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemverOrder(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{a: "1.9.0", b: "1.10.0", want: -1},
+		{a: "1.10.0", b: "1.9.0", want: 1},
+		{a: "2.0.0", b: "10.0.0", want: -1},
+		{a: "1.0.0-alpha", b: "1.0.0", want: -1},
+		{a: "1.0.0-alpha", b: "1.0.0-alpha.1", want: -1},
+		{a: "1.0.0-alpha.1", b: "1.0.0-alpha.beta", want: -1},
+		{a: "1.0.0-alpha.beta", b: "1.0.0-beta", want: -1},
+		{a: "1.0.0-beta.2", b: "1.0.0-beta.11", want: -1},
+		{a: "1.0.0-beta.11", b: "1.0.0-rc.1", want: -1},
+		{a: "1.0.0-rc.1", b: "1.0.0", want: -1},
+		{a: "1.2", b: "1.2.1", want: -1},
+		{a: "v1.2.0", b: "1.3.0", want: -1},
+		{a: "1.0.0", b: "v1.0.0", want: -1},
+		{a: "1.0.0", b: "1.0.0+build", want: -1},
+		{a: "99999999999999999999.0.0", b: "100000000000000000000.0.0", want: -1},
+		{a: "1.0.0", b: "not a version", want: -1},
+		{a: "banana", b: "apple", want: 1},
+		{a: "1.0.0", b: "1.0.0", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.want, SemverOrder.Compare(tt.a, tt.b))
+			assert.Equal(t, -tt.want, SemverOrder.Compare(tt.b, tt.a))
+		})
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		s      string
+		want   version
+		wantOk bool
+	}{
+		{
+			s:      "1.2.3",
+			want:   version{major: "1", minor: "2", patch: "3", parts: 3},
+			wantOk: true,
+		},
+		{
+			s:      "v01.2",
+			want:   version{major: "1", minor: "2", patch: "0", parts: 2},
+			wantOk: true,
+		},
+		{
+			s:      "1-rc.1+build.5",
+			want:   version{major: "1", minor: "0", patch: "0", parts: 1, pre: "rc.1"},
+			wantOk: true,
+		},
+		{s: ""},
+		{s: "v"},
+		{s: "1."},
+		{s: "1..2"},
+		{s: "1.2.3.4"},
+		{s: "1.a"},
+		{s: "1.2.3-"},
+		{s: "1.2.3-a..b"},
+		{s: "1.2.3+"},
+		{s: "1.2.3-a_b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, ok := parseVersion(tt.s)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOk, ok)
+		})
+	}
+}
+
+func TestTreap_VersionRange(t *testing.T) {
+	values := []string{
+		"0.9.0", "1.0.0-rc.1", "1.0.0", "1.2.0", "v1.2.5", "1.2.10",
+		"1.3.0", "1.10.0", "2.0.0-beta", "2.0.0", "2.1.0", "latest",
+	}
+	tests := []struct {
+		name       string
+		constraint string
+		want       []string
+		wantErr    error
+	}{
+		{
+			name:       "range",
+			constraint: ">=1.2 <2",
+			want: []string{
+				"1.2.0", "v1.2.5", "1.2.10", "1.3.0", "1.10.0", "2.0.0-beta",
+			},
+		},
+		{
+			name:       "exclusive bounds",
+			constraint: ">1.2.0 <=1.3.0",
+			want:       []string{"v1.2.5", "1.2.10", "1.3.0"},
+		},
+		{
+			name:       "partial version",
+			constraint: "1.2",
+			want:       []string{"1.2.0", "v1.2.5", "1.2.10"},
+		},
+		{
+			name:       "exact version",
+			constraint: "=1.2.5",
+			want:       []string{"v1.2.5"},
+		},
+		{
+			name:       "lower bound only",
+			constraint: ">= 2",
+			want:       []string{"2.0.0", "2.1.0"},
+		},
+		{
+			name:       "upper bound only",
+			constraint: "<1.0.0",
+			want:       []string{"0.9.0", "1.0.0-rc.1"},
+		},
+		{
+			name:       "alternatives",
+			constraint: "<1 || 1.10 || >2",
+			want:       []string{"0.9.0", "1.0.0-rc.1", "1.10.0", "2.1.0"},
+		},
+		{
+			name:       "no versions",
+			constraint: ">3",
+		},
+		{
+			name:       "not a version",
+			constraint: ">=latest",
+			wantErr:    ErrInvalidConstraint,
+		},
+		{
+			name:       "empty alternative",
+			constraint: "1 ||",
+			wantErr:    ErrInvalidConstraint,
+		},
+	}
+	for _, tt := range tests {
+		for _, order := range []Order{SemverOrder, ByteOrder} {
+			t.Run(tt.name, func(t *testing.T) {
+				trp := NewOrderedTreap(order)
+				for _, v := range values {
+					trp.Insert(v)
+				}
+
+				var got []string
+				err := trp.VersionRange(tt.constraint, func(value string) bool {
+					got = append(got, value)
+					return true
+				})
+				assert.True(t, errors.Is(err, tt.wantErr))
+
+				// a Treap in another order walks every value
+				// and reports them in its order
+				want := tt.want
+				if order != SemverOrder && want != nil {
+					want = nil
+					trp.Iterate(func(value string) bool {
+						for _, w := range tt.want {
+							if w == value {
+								want = append(want, value)
+							}
+						}
+						return true
+					})
+				}
+				assert.Equal(t, want, got)
+			})
+		}
+	}
+}
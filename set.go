@@ -0,0 +1,64 @@
+package treap
+
+// IsSubset returns true if every value of Treap a is in Treap b.
+// The Treaps must be in the same order.
+// The Treaps are walked together in ascending order, skipping ahead in
+// b past the values missing from a, until a value of a is found to be
+// missing from b.
+func IsSubset(a, b *Treap) bool {
+	if a.root == b.root {
+		return true
+	}
+
+	ca, cb := newCursor(a.root, a.order), newCursor(b.root, a.order)
+	for na, ok := ca.next(); ok; na, ok = ca.next() {
+		nb, found := cb.nextFrom(b.root, na.value)
+		if !found || nb.value != na.value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsSuperset returns true if every value of Treap b is in Treap a.
+func IsSuperset(a, b *Treap) bool {
+	return IsSubset(b, a)
+}
+
+// IsDisjoint returns true if Treaps a and b have no values in common.
+// The Treaps must be in the same order.
+// Each Treap is walked in ascending order, skipping ahead to the
+// latest value of the other, until a common value is found.
+func IsDisjoint(a, b *Treap) bool {
+	if a.root == nil || b.root == nil {
+		return true
+	}
+	if a.root == b.root {
+		return false
+	}
+
+	ca, cb := newCursor(a.root, a.order), newCursor(b.root, a.order)
+	na, _ := ca.next()
+	nb, _ := cb.next()
+	for {
+		var ok bool
+		switch {
+		case na.value == nb.value:
+			return false
+		case less(a.order, na.value, nb.value):
+			na, ok = ca.nextFrom(a.root, nb.value)
+		default:
+			nb, ok = cb.nextFrom(b.root, na.value)
+		}
+
+		if !ok {
+			return true
+		}
+	}
+}
+
+// Overlaps returns true if Treaps a and b have a value in common.
+func Overlaps(a, b *Treap) bool {
+	return !IsDisjoint(a, b)
+}
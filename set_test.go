@@ -0,0 +1,131 @@
+package treap
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetPredicates(t *testing.T) {
+	tests := []struct {
+		name         string
+		a            []string
+		b            []string
+		wantSubset   bool
+		wantSuperset bool
+		wantDisjoint bool
+	}{
+		{
+			name:         "empty treaps",
+			wantSubset:   true,
+			wantSuperset: true,
+			wantDisjoint: true,
+		},
+		{
+			name:         "empty subset",
+			b:            []string{"a"},
+			wantSubset:   true,
+			wantDisjoint: true,
+		},
+		{
+			name:         "equal treaps",
+			a:            []string{"a", "b", "c"},
+			b:            []string{"a", "b", "c"},
+			wantSubset:   true,
+			wantSuperset: true,
+		},
+		{
+			name:       "proper subset",
+			a:          []string{"b", "d"},
+			b:          []string{"a", "b", "c", "d", "e"},
+			wantSubset: true,
+		},
+		{
+			name:         "proper superset",
+			a:            []string{"a", "b", "c", "d", "e"},
+			b:            []string{"a", "e"},
+			wantSuperset: true,
+		},
+		{
+			name: "overlapping treaps",
+			a:    []string{"a", "b", "c"},
+			b:    []string{"c", "d"},
+		},
+		{
+			name:         "interleaved disjoint treaps",
+			a:            []string{"a", "c", "e"},
+			b:            []string{"b", "d", "f"},
+			wantDisjoint: true,
+		},
+		{
+			name:         "separated disjoint treaps",
+			a:            []string{"a", "b"},
+			b:            []string{"x", "y"},
+			wantDisjoint: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := NewTreap(), NewTreap()
+			for _, v := range tt.a {
+				a.Insert(v)
+			}
+			for _, v := range tt.b {
+				b.Insert(v)
+			}
+
+			assert.Equal(t, tt.wantSubset, IsSubset(a, b))
+			assert.Equal(t, tt.wantSuperset, IsSuperset(a, b))
+			assert.Equal(t, tt.wantDisjoint, IsDisjoint(a, b))
+			assert.Equal(t, tt.wantDisjoint, IsDisjoint(b, a))
+			assert.Equal(t, !tt.wantDisjoint, Overlaps(a, b))
+		})
+	}
+}
+
+func TestSetPredicates_Large(t *testing.T) {
+	granted := NewTreap()
+	for i := 0; i < 10000; i++ {
+		granted.Insert(fmt.Sprintf("scope-%05d", i))
+	}
+
+	required := NewTreap()
+	for i := 0; i < 10000; i += 997 {
+		required.Insert(fmt.Sprintf("scope-%05d", i))
+	}
+	assert.True(t, IsSubset(required, granted))
+	assert.True(t, IsSuperset(granted, required))
+	assert.True(t, Overlaps(required, granted))
+
+	required.Insert("scope-99999")
+	assert.False(t, IsSubset(required, granted))
+
+	other := NewTreap()
+	for i := 0; i < 10000; i++ {
+		other.Insert(fmt.Sprintf("other-%05d", i))
+	}
+	assert.True(t, IsDisjoint(granted, other))
+
+	clone := granted.Clone()
+	assert.True(t, IsSubset(clone, granted))
+	assert.True(t, Overlaps(clone, granted))
+}
+
+func TestCursor_nextFrom(t *testing.T) {
+	trp := NewTreap()
+	for i := 0; i < 100; i++ {
+		trp.Insert(fmt.Sprintf("%02d", i))
+	}
+
+	c := newCursor(trp.root, nil)
+	var values []string
+	for _, low := range []string{"", "05", "06", "50", "49", "98x"} {
+		n, ok := c.nextFrom(trp.root, low)
+		if ok {
+			values = append(values, n.value)
+		}
+	}
+
+	assert.Equal(t, []string{"00", "05", "06", "50", "51", "99"}, values)
+}
@@ -0,0 +1,446 @@
+package treap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"strings"
+)
+
+// The binary snapshot format of a Treap is laid out as follows,
+// with all integers encoded as unsigned varints unless noted:
+//
+//	magic    4 bytes, "TRPS"
+//	version  1 byte, the version of the format
+//	flags    1 byte, snapshotPriorities if priorities are included
+//	count    the number of values
+//	shape    2 bits per node in pre-order, set if the node has a
+//	         left and a right child respectively, packed from the
+//	         least significant bit and padded to a whole byte
+//	values   per value in ascending order, the length of the prefix
+//	         shared with the previous value, the length of the rest
+//	         of the value, the rest of the value, and its priority
+//	         if priorities are included
+//	checksum 4 bytes, little endian CRC-32 (IEEE) of all prior bytes
+const (
+	snapshotMagic   = "TRPS"
+	snapshotVersion = 1
+
+	// snapshotPriorities is the flag set when priorities are included.
+	snapshotPriorities = 1 << 0
+)
+
+// ErrInvalidSnapshot is returned when reading a snapshot that is
+// malformed or fails its checksum.
+var ErrInvalidSnapshot = errors.New("treap: invalid snapshot")
+
+// WriteTo writes a binary snapshot of the Treap, including the
+// priorities of its values, to the given writer.
+// Returns the number of bytes written.
+func (t *Treap) WriteTo(w io.Writer) (int64, error) {
+	return t.WriteSnapshot(w, true)
+}
+
+// WriteSnapshot writes a binary snapshot of the Treap to the given
+// writer. The snapshot records the exact shape of the Treap, and also
+// the priorities of its values if priorities is true. Omitting the
+// priorities makes the snapshot smaller.
+// Returns the number of bytes written.
+func (t *Treap) WriteSnapshot(w io.Writer, priorities bool) (int64, error) {
+	sw := &snapshotWriter{
+		w:   bufio.NewWriter(w),
+		crc: crc32.NewIEEE(),
+	}
+
+	var flags byte
+	if priorities {
+		flags |= snapshotPriorities
+	}
+	sw.Write([]byte(snapshotMagic))
+	sw.Write([]byte{snapshotVersion, flags})
+
+	count := 0
+	inorder(t.root, func(n *node) bool {
+		count++
+		return true
+	})
+	sw.uvarint(uint64(count))
+
+	var bits byte
+	var nbits uint
+	preorder(t.root, func(n *node) {
+		if n.left != nil {
+			bits |= 1 << nbits
+		}
+		if n.right != nil {
+			bits |= 1 << (nbits + 1)
+		}
+
+		nbits += 2
+		if nbits == 8 {
+			sw.Write([]byte{bits})
+			bits, nbits = 0, 0
+		}
+	})
+	if nbits > 0 {
+		sw.Write([]byte{bits})
+	}
+
+	var prev string
+	inorder(t.root, func(n *node) bool {
+		shared := commonPrefixLen(prev, n.value)
+		sw.uvarint(uint64(shared))
+		sw.uvarint(uint64(len(n.value) - shared))
+		sw.Write([]byte(n.value[shared:]))
+		if priorities {
+			sw.uvarint(uint64(n.priority))
+		}
+
+		prev = n.value
+		return sw.err == nil
+	})
+
+	return sw.close()
+}
+
+// ReadFrom replaces the contents of the Treap with the binary snapshot
+// read from the given reader. The values of the snapshot must be in the
+// order of the Treap. The Treap takes the exact shape recorded in the
+// snapshot in linear time. If the snapshot doesn't include priorities,
+// the values are given priorities that descend in level order.
+// A keyed Treap instead gives the values their keyed priorities and
+// inserts them one by one to take the shape that they determine, which
+// takes O(n log n) time. Since the replacement can't be undone, the
+// history of the Treap is cleared. The Treap is unchanged if an error is
+// returned.
+//
+// ReadFrom reads no further than the end of the snapshot, so whatever
+// follows it can be read from the reader after. A reader that doesn't
+// implement io.ByteReader is read without buffering, so a file is best
+// wrapped in a bufio.Reader, from which the rest can still be read.
+// Returns the number of bytes read.
+func (t *Treap) ReadFrom(r io.Reader) (int64, error) {
+	sr := newSnapshotReader(r)
+
+	header := make([]byte, len(snapshotMagic)+2)
+	if _, err := io.ReadFull(sr, header); err != nil {
+		return sr.n, unexpectedEOF(err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return sr.n, fmt.Errorf("%w: bad magic", ErrInvalidSnapshot)
+	}
+	if version := header[len(snapshotMagic)]; version != snapshotVersion {
+		return sr.n, fmt.Errorf("%w: unsupported version %d",
+			ErrInvalidSnapshot, version)
+	}
+	priorities := header[len(snapshotMagic)+1]&snapshotPriorities != 0
+
+	count, err := binary.ReadUvarint(sr)
+	if err != nil {
+		return sr.n, unexpectedEOF(err)
+	}
+
+	root, err := sr.readShape(count, t.owner)
+	if err != nil {
+		return sr.n, err
+	}
+	if err := sr.readValues(root, priorities, t.order); err != nil {
+		return sr.n, err
+	}
+	if !priorities {
+		levelOrderPriorities(root, count)
+	}
+	if err := sr.verify(); err != nil {
+		return sr.n, err
+	}
+	if t.Keyed() {
+		root = t.rekey(root)
+	}
+
+	t.replace(root)
+	return sr.n, nil
+}
+
+// snapshotWriter writes a binary snapshot while computing its checksum.
+// The first error encountered is retained and stops further writes.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	crc hash.Hash32
+	n   int64
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+// Write writes the passed bytes and adds them to the checksum.
+func (sw *snapshotWriter) Write(p []byte) (int, error) {
+	if sw.err != nil {
+		return 0, sw.err
+	}
+
+	n, err := sw.w.Write(p)
+	sw.crc.Write(p[:n])
+	sw.n += int64(n)
+	sw.err = err
+	return n, err
+}
+
+// uvarint writes the passed integer as an unsigned varint.
+func (sw *snapshotWriter) uvarint(x uint64) {
+	sw.Write(sw.buf[:binary.PutUvarint(sw.buf[:], x)])
+}
+
+// close writes the checksum and flushes the snapshot.
+// Returns the number of bytes written.
+func (sw *snapshotWriter) close() (int64, error) {
+	if sw.err != nil {
+		return sw.n, sw.err
+	}
+
+	var footer [4]byte
+	binary.LittleEndian.PutUint32(footer[:], sw.crc.Sum32())
+	n, err := sw.w.Write(footer[:])
+	sw.n += int64(n)
+	if err != nil {
+		return sw.n, err
+	}
+
+	return sw.n, sw.w.Flush()
+}
+
+// snapshotReader reads a binary snapshot while computing its checksum.
+type snapshotReader struct {
+	r interface {
+		io.Reader
+		io.ByteReader
+	}
+	crc hash.Hash32
+	n   int64
+}
+
+// newSnapshotReader returns a snapshotReader that reads from the passed
+// reader. A reader that can't read single bytes is read one byte at a
+// time where needed rather than buffered, since buffering would read
+// past the end of the snapshot.
+func newSnapshotReader(r io.Reader) *snapshotReader {
+	sr := &snapshotReader{
+		crc: crc32.NewIEEE(),
+	}
+
+	if br, ok := r.(interface {
+		io.Reader
+		io.ByteReader
+	}); ok {
+		sr.r = br
+	} else {
+		sr.r = &byteReader{Reader: r}
+	}
+
+	return sr
+}
+
+// byteReader reads single bytes from a reader without reading ahead.
+type byteReader struct {
+	io.Reader
+	buf [1]byte
+}
+
+// ReadByte reads a single byte.
+func (br *byteReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(br.Reader, br.buf[:]); err != nil {
+		return 0, err
+	}
+	return br.buf[0], nil
+}
+
+// Read reads into the passed bytes and adds them to the checksum.
+func (sr *snapshotReader) Read(p []byte) (int, error) {
+	n, err := sr.r.Read(p)
+	sr.crc.Write(p[:n])
+	sr.n += int64(n)
+	return n, err
+}
+
+// ReadByte reads a single byte and adds it to the checksum.
+func (sr *snapshotReader) ReadByte() (byte, error) {
+	b, err := sr.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	sr.crc.Write([]byte{b})
+	sr.n++
+	return b, nil
+}
+
+// readShape reads the shape of a tree with count nodes and returns its
+// root. The nodes are tagged with the passed owner and have no values.
+func (sr *snapshotReader) readShape(count uint64, owner uint64) (*node, error) {
+	var root *node
+
+	// links holds the child links that are yet to be filled in pre-order
+	var links []**node
+	if count > 0 {
+		links = append(links, &root)
+	}
+
+	var bits byte
+	for i := uint64(0); i < count; i++ {
+		if len(links) == 0 {
+			return nil, fmt.Errorf("%w: shape has too few nodes",
+				ErrInvalidSnapshot)
+		}
+
+		if i%4 == 0 {
+			b, err := sr.ReadByte()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			bits = b
+		}
+
+		n := &node{owner: owner}
+		link := links[len(links)-1]
+		links = links[:len(links)-1]
+		*link = n
+
+		shift := (i % 4) * 2
+		if bits&(1<<(shift+1)) != 0 {
+			links = append(links, &n.right)
+		}
+		if bits&(1<<shift) != 0 {
+			links = append(links, &n.left)
+		}
+	}
+
+	if len(links) != 0 {
+		return nil, fmt.Errorf("%w: shape has too many nodes",
+			ErrInvalidSnapshot)
+	}
+
+	return root, nil
+}
+
+// readValues reads the values, and the priorities if included, of the
+// tree rooted at the passed node, which must be in the passed order.
+func (sr *snapshotReader) readValues(root *node, priorities bool, order Order) error {
+	var err error
+	var prev string
+	first := true
+	inorder(root, func(n *node) bool {
+		var shared, rest uint64
+		if shared, err = binary.ReadUvarint(sr); err != nil {
+			err = unexpectedEOF(err)
+			return false
+		}
+		if rest, err = binary.ReadUvarint(sr); err != nil {
+			err = unexpectedEOF(err)
+			return false
+		}
+		if shared > uint64(len(prev)) || rest > math.MaxInt64 {
+			err = fmt.Errorf("%w: value length out of range",
+				ErrInvalidSnapshot)
+			return false
+		}
+
+		var b strings.Builder
+		b.WriteString(prev[:shared])
+		if _, err = io.CopyN(&b, sr, int64(rest)); err != nil {
+			err = unexpectedEOF(err)
+			return false
+		}
+		n.value = b.String()
+		if !first && !less(order, prev, n.value) {
+			err = fmt.Errorf("%w: values out of order", ErrInvalidSnapshot)
+			return false
+		}
+
+		if priorities {
+			var priority uint64
+			if priority, err = binary.ReadUvarint(sr); err != nil {
+				err = unexpectedEOF(err)
+				return false
+			}
+			if priority > math.MaxInt64 {
+				err = fmt.Errorf("%w: priority out of range",
+					ErrInvalidSnapshot)
+				return false
+			}
+			n.priority = int64(priority)
+		}
+
+		prev = n.value
+		first = false
+		return true
+	})
+
+	if err != nil || !priorities {
+		return err
+	}
+
+	valid := true
+	preorder(root, func(n *node) {
+		valid = valid &&
+			(n.left == nil || n.left.priority <= n.priority) &&
+			(n.right == nil || n.right.priority <= n.priority)
+	})
+	if !valid {
+		return fmt.Errorf("%w: priorities out of order", ErrInvalidSnapshot)
+	}
+
+	return nil
+}
+
+// verify reads the checksum of the snapshot and compares it to
+// the checksum of the bytes read.
+func (sr *snapshotReader) verify() error {
+	var footer [4]byte
+	n, err := io.ReadFull(sr.r, footer[:])
+	sr.n += int64(n)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	if binary.LittleEndian.Uint32(footer[:]) != sr.crc.Sum32() {
+		return fmt.Errorf("%w: checksum mismatch", ErrInvalidSnapshot)
+	}
+
+	return nil
+}
+
+// levelOrderPriorities gives the count nodes of the tree rooted at the
+// passed node priorities that are evenly spread over the range of
+// priorities and descend in level order.
+func levelOrderPriorities(root *node, count uint64) {
+	step := int64(maxPriority / (count + 1))
+	priority := int64(maxPriority)
+	levelorder(root, func(n *node) error {
+		n.priority = priority
+		priority -= step
+		return nil
+	})
+}
+
+// commonPrefixLen returns the length of the longest
+// common prefix of the passed strings.
+func commonPrefixLen(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// unexpectedEOF returns io.ErrUnexpectedEOF if the passed error is
+// io.EOF, since a snapshot never ends where a read is still expected.
+// Otherwise, returns the passed error.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
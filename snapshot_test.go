@@ -0,0 +1,211 @@
+package treap
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreap_WriteTo(t *testing.T) {
+	tests := []struct {
+		name  string
+		count int
+	}{
+		{
+			name:  "empty treap",
+			count: 0,
+		},
+		{
+			name:  "single value treap",
+			count: 1,
+		},
+		{
+			name:  "populated treap",
+			count: 5000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trp := NewTreap()
+			fillTree(trp, tt.count)
+
+			var buf bytes.Buffer
+			written, err := trp.WriteTo(&buf)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(buf.Len()), written)
+
+			// Assert that the exact shape and priorities are restored
+			loaded := NewTreap()
+			read, err := loaded.ReadFrom(&buf)
+			assert.NoError(t, err)
+			assert.Equal(t, written, read)
+			assert.Equal(t, trp.root, loaded.root)
+			assert.Equal(t, 0, buf.Len())
+		})
+	}
+}
+
+func TestTreap_WriteSnapshotWithoutPriorities(t *testing.T) {
+	trp := NewTreap()
+	fillTree(trp, 5000)
+
+	var withPriorities, withoutPriorities bytes.Buffer
+	_, err := trp.WriteSnapshot(&withPriorities, true)
+	assert.NoError(t, err)
+	_, err = trp.WriteSnapshot(&withoutPriorities, false)
+	assert.NoError(t, err)
+	assert.True(t, withoutPriorities.Len() < withPriorities.Len())
+
+	loaded := NewTreap()
+	_, err = loaded.ReadFrom(&withoutPriorities)
+	assert.NoError(t, err)
+	assert.True(t, sameShape(trp.root, loaded.root))
+	assert.True(t, hasTreapProperties(loaded.root))
+
+	// Assert that the loaded treap remains usable
+	inserted := fillTree(loaded, 1000)
+	for k := range inserted {
+		loaded.Delete(k)
+	}
+	assert.True(t, hasTreapProperties(loaded.root))
+}
+
+func TestTreap_ReadFrom_Stream(t *testing.T) {
+	trp := NewTreap()
+	fillTree(trp, 1000)
+
+	var buf bytes.Buffer
+	written, err := trp.WriteTo(&buf)
+	assert.NoError(t, err)
+	buf.WriteString("trailer")
+
+	tests := []struct {
+		name   string
+		reader func(data []byte) io.Reader
+	}{
+		{
+			name: "byte reader",
+			reader: func(data []byte) io.Reader {
+				return bufio.NewReader(bytes.NewReader(data))
+			},
+		},
+		{
+			name: "plain reader",
+			reader: func(data []byte) io.Reader {
+				return struct{ io.Reader }{bytes.NewReader(data)}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.reader(buf.Bytes())
+
+			// Assert that the snapshot is read up to its end and no further
+			loaded := NewTreap()
+			read, err := loaded.ReadFrom(r)
+			assert.NoError(t, err)
+			assert.Equal(t, written, read)
+			assert.Equal(t, collect(trp), collect(loaded))
+
+			rest, err := ioutil.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, "trailer", string(rest))
+		})
+	}
+}
+
+func TestTreap_ReadFrom(t *testing.T) {
+	trp := NewTreap()
+	for _, v := range []string{"apple", "apricot", "banana", "band"} {
+		trp.Insert(v)
+	}
+	var buf bytes.Buffer
+	_, err := trp.WriteTo(&buf)
+	assert.NoError(t, err)
+	snapshot := buf.Bytes()
+
+	tests := []struct {
+		name    string
+		data    func() []byte
+		wantErr error
+	}{
+		{
+			name: "valid snapshot",
+			data: func() []byte {
+				return snapshot
+			},
+		},
+		{
+			name: "bad magic",
+			data: func() []byte {
+				data := append([]byte(nil), snapshot...)
+				data[0] = 'X'
+				return data
+			},
+			wantErr: ErrInvalidSnapshot,
+		},
+		{
+			name: "unsupported version",
+			data: func() []byte {
+				data := append([]byte(nil), snapshot...)
+				data[len(snapshotMagic)] = snapshotVersion + 1
+				return data
+			},
+			wantErr: ErrInvalidSnapshot,
+		},
+		{
+			name: "corrupted value",
+			data: func() []byte {
+				data := append([]byte(nil), snapshot...)
+				data[len(data)-8] ^= 0xff
+				return data
+			},
+			wantErr: ErrInvalidSnapshot,
+		},
+		{
+			name: "truncated snapshot",
+			data: func() []byte {
+				return snapshot[:len(snapshot)-2]
+			},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name: "empty input",
+			data: func() []byte {
+				return nil
+			},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded := NewTreap()
+			loaded.Insert("existing")
+
+			_, err := loaded.ReadFrom(bytes.NewReader(tt.data()))
+			assert.True(t, errors.Is(err, tt.wantErr))
+			if tt.wantErr != nil {
+				assert.Equal(t, []string{"existing"}, collect(loaded))
+			} else {
+				assert.Equal(t, collect(trp), collect(loaded))
+			}
+		})
+	}
+}
+
+// sameShape returns true if the trees rooted at the passed
+// nodes have the same shape and values.
+func sameShape(a, b *node) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.value == b.value &&
+		sameShape(a.left, b.left) &&
+		sameShape(a.right, b.right)
+}
@@ -0,0 +1,459 @@
+// Package store provides a durable sorted set of strings
+// by backing a Treap with a write-ahead log and snapshots.
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/austingebauer/go-treap"
+)
+
+const (
+	// The names of the files kept in the directory of a Store.
+	logName          = "wal"
+	snapshotName     = "snapshot"
+	snapshotTempName = "snapshot.tmp"
+
+	// The operations recorded in the write-ahead log.
+	opInsert = 1
+	opDelete = 2
+
+	// Each record in the write-ahead log starts with the little endian
+	// length of its payload followed by the CRC-32 (IEEE) of the payload.
+	// The payload is the operation followed by the value.
+	recordHeaderSize = 8
+)
+
+// ErrClosed is returned when using a Store that has been closed.
+var ErrClosed = errors.New("store: closed")
+
+// Options configures a Store.
+type Options struct {
+	// SnapshotEvery is the number of mutations recorded in the
+	// write-ahead log after which a snapshot compacts the log.
+	// Zero disables periodic snapshots.
+	SnapshotEvery int
+
+	// GroupCommit lets concurrent mutations share a single fsync of
+	// the write-ahead log instead of each mutation doing its own.
+	// A mutation is visible to readers once it's written to the log,
+	// which may be before it's durable.
+	GroupCommit bool
+}
+
+// DefaultOptions are the Options used by Open.
+var DefaultOptions = Options{
+	SnapshotEvery: 100000,
+}
+
+// Store is a durable sorted set of strings. Each mutation is recorded
+// in a write-ahead log and fsynced before it's acknowledged, and the
+// log is periodically compacted into a snapshot of the Treap.
+// A Store is safe for concurrent use.
+type Store struct {
+	mu    sync.RWMutex
+	dir   string
+	opts  Options
+	treap *treap.Treap
+	log   *os.File
+
+	// written is the number of mutations written to the log, accessed
+	// atomically, and logged is the number of those since the last
+	// snapshot.
+	written uint64
+	logged  int
+
+	// syncMu serializes fsyncs of the log, and synced is the number
+	// of mutations known to be durable. When both mu and syncMu are
+	// held, mu is acquired first.
+	syncMu sync.Mutex
+	synced uint64
+
+	// err is the first error that left the log in an unknown state.
+	errMu sync.Mutex
+	err   error
+
+	closed bool
+}
+
+// Open opens the Store in the given directory using DefaultOptions,
+// creating the directory if it doesn't exist.
+func Open(dir string) (*Store, error) {
+	return OpenWithOptions(dir, DefaultOptions)
+}
+
+// OpenWithOptions opens the Store in the given directory using the
+// given options, creating the directory if it doesn't exist.
+// The contents of the Store are recovered from its latest snapshot and
+// write-ahead log. A record that was torn by a crash while being written,
+// and anything logged after it, is discarded from the end of the log.
+func OpenWithOptions(dir string, opts Options) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		dir:   dir,
+		opts:  opts,
+		treap: treap.NewTreap(),
+	}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	log, err := os.OpenFile(filepath.Join(dir, logName),
+		os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s.log = log
+
+	if err := s.replayLog(); err != nil {
+		log.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Search returns true if the given value is in the Store.
+// Otherwise, returns false.
+func (s *Store) Search(value string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.treap.Search(value)
+}
+
+// Iterate calls fn for each value in the Store in ascending order.
+// Iteration stops early if fn returns false. The Store can't be
+// mutated by fn.
+func (s *Store) Iterate(fn func(value string) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.treap.Iterate(fn)
+}
+
+// Insert durably inserts the given value into the Store.
+func (s *Store) Insert(value string) error {
+	return s.mutate(opInsert, value)
+}
+
+// Delete durably deletes the given value from the Store.
+func (s *Store) Delete(value string) error {
+	return s.mutate(opDelete, value)
+}
+
+// Snapshot writes a snapshot of the Store and compacts its
+// write-ahead log.
+func (s *Store) Snapshot() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	return s.snapshot()
+}
+
+// Close closes the Store. Mutations that were acknowledged are durable.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	s.closed = true
+
+	// make mutations that are waiting on group commit durable
+	s.syncMu.Lock()
+	err := s.log.Sync()
+	if err == nil {
+		s.synced = atomic.LoadUint64(&s.written)
+	}
+	s.syncMu.Unlock()
+
+	if cerr := s.log.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// mutate records the passed operation in the log, applies it
+// to the Treap, and waits until it's durable.
+func (s *Store) mutate(op byte, value string) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+
+	if err := s.append(op, value); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	seq := atomic.LoadUint64(&s.written)
+
+	if !s.opts.GroupCommit {
+		defer s.mu.Unlock()
+		if err := s.sync(seq); err != nil {
+			return err
+		}
+		return s.compact()
+	}
+
+	err := s.compact()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return s.sync(seq)
+}
+
+// append writes the passed operation to the log and applies it to the
+// Treap. It must be called with mu held.
+func (s *Store) append(op byte, value string) error {
+	if err := s.failed(); err != nil {
+		return err
+	}
+
+	record := make([]byte, recordHeaderSize+1+len(value))
+	record[recordHeaderSize] = op
+	copy(record[recordHeaderSize+1:], value)
+	payload := record[recordHeaderSize:]
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+
+	if _, err := s.log.Write(record); err != nil {
+		s.fail(err)
+		return err
+	}
+
+	apply(s.treap, op, value)
+	atomic.AddUint64(&s.written, 1)
+	s.logged++
+	return nil
+}
+
+// sync waits until the first seq mutations written to the log are
+// durable, fsyncing the log if they aren't yet. Mutations written
+// while another fsync is in progress share the next fsync.
+func (s *Store) sync(seq uint64) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	if err := s.failed(); err != nil {
+		return err
+	}
+	if s.synced >= seq {
+		return nil
+	}
+
+	// mutations may be written during the fsync, so only
+	// those written before it starts are known to be durable
+	target := atomic.LoadUint64(&s.written)
+	if err := s.log.Sync(); err != nil {
+		s.fail(err)
+		return err
+	}
+	if target > s.synced {
+		s.synced = target
+	}
+
+	return nil
+}
+
+// compact writes a snapshot if enough mutations were logged since
+// the last one. It must be called with mu held.
+func (s *Store) compact() error {
+	if s.opts.SnapshotEvery <= 0 || s.logged < s.opts.SnapshotEvery {
+		return nil
+	}
+	return s.snapshot()
+}
+
+// snapshot atomically replaces the snapshot of the Store with one of
+// the Treap, then truncates the log. It must be called with mu held.
+//
+// If a crash happens after the snapshot is replaced but before the log
+// is truncated, the log is replayed onto a snapshot that already
+// includes it. That's harmless since replaying a sequence of inserts
+// and deletes onto its own result doesn't change the result.
+func (s *Store) snapshot() error {
+	if err := s.failed(); err != nil {
+		return err
+	}
+
+	temp := filepath.Join(s.dir, snapshotTempName)
+	f, err := os.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := s.treap.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temp, filepath.Join(s.dir, snapshotName)); err != nil {
+		return err
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+
+	// everything written to the log is now durable in the snapshot
+	if err := s.log.Truncate(0); err != nil {
+		s.fail(err)
+		return err
+	}
+	if _, err := s.log.Seek(0, io.SeekStart); err != nil {
+		s.fail(err)
+		return err
+	}
+	if err := s.log.Sync(); err != nil {
+		s.fail(err)
+		return err
+	}
+
+	s.logged = 0
+	s.syncMu.Lock()
+	s.synced = atomic.LoadUint64(&s.written)
+	s.syncMu.Unlock()
+	return nil
+}
+
+// loadSnapshot loads the snapshot of the Store into the Treap,
+// if there is one.
+func (s *Store) loadSnapshot() error {
+	f, err := os.Open(filepath.Join(s.dir, snapshotName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = s.treap.ReadFrom(bufio.NewReader(f))
+	return err
+}
+
+// replayLog applies the records of the log to the Treap, then
+// truncates the log after its last intact record.
+func (s *Store) replayLog() error {
+	data, err := readAll(s.log)
+	if err != nil {
+		return err
+	}
+
+	offset := 0
+	for {
+		n, op, value, ok := decodeRecord(data[offset:])
+		if !ok {
+			break
+		}
+
+		apply(s.treap, op, value)
+		offset += n
+		s.logged++
+	}
+
+	if offset < len(data) {
+		if err := s.log.Truncate(int64(offset)); err != nil {
+			return err
+		}
+		if err := s.log.Sync(); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.log.Seek(int64(offset), io.SeekStart)
+	return err
+}
+
+// failed returns the error that left the log in an unknown state, if any.
+func (s *Store) failed() error {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	return s.err
+}
+
+// fail records the passed error as having left the log in an unknown
+// state, so that all later mutations fail.
+func (s *Store) fail(err error) {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// decodeRecord decodes the log record at the start of the passed bytes.
+// Returns the size of the record, its operation and value, and false
+// if the bytes don't start with an intact record.
+func decodeRecord(data []byte) (int, byte, string, bool) {
+	if len(data) < recordHeaderSize {
+		return 0, 0, "", false
+	}
+
+	size := binary.LittleEndian.Uint32(data[0:4])
+	sum := binary.LittleEndian.Uint32(data[4:8])
+	if size == 0 || uint64(size) > uint64(len(data)-recordHeaderSize) {
+		return 0, 0, "", false
+	}
+
+	payload := data[recordHeaderSize : recordHeaderSize+int(size)]
+	if crc32.ChecksumIEEE(payload) != sum {
+		return 0, 0, "", false
+	}
+
+	op := payload[0]
+	if op != opInsert && op != opDelete {
+		return 0, 0, "", false
+	}
+
+	return recordHeaderSize + int(size), op, string(payload[1:]), true
+}
+
+// apply applies the passed operation to the passed Treap.
+func apply(t *treap.Treap, op byte, value string) {
+	if op == opInsert {
+		t.Insert(value)
+	} else {
+		t.Delete(value)
+	}
+}
+
+// readAll reads the passed file from its start.
+func readAll(f *os.File) ([]byte, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(f)
+}
+
+// syncDir fsyncs the passed directory so that renames within it are durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,196 @@
+package store
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpen(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := Open(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Insert("c"))
+	assert.NoError(t, s.Insert("a"))
+	assert.NoError(t, s.Insert("b"))
+	assert.NoError(t, s.Delete("c"))
+	assert.NoError(t, s.Close())
+
+	// Assert that the contents are recovered from the log
+	s, err = Open(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, values(s))
+	assert.True(t, s.Search("a"))
+	assert.False(t, s.Search("c"))
+	assert.NoError(t, s.Close())
+
+	assert.Equal(t, ErrClosed, s.Insert("d"))
+	assert.Equal(t, ErrClosed, s.Close())
+}
+
+func TestStore_Snapshot(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := OpenWithOptions(dir, Options{SnapshotEvery: 10})
+	assert.NoError(t, err)
+
+	var want []string
+	for i := 0; i < 25; i++ {
+		v := fmt.Sprintf("%02d", i)
+		assert.NoError(t, s.Insert(v))
+		want = append(want, v)
+	}
+
+	// Assert that the log was compacted by the periodic snapshots
+	assert.Equal(t, int64(5*(recordHeaderSize+3)), fileSize(t, dir, logName))
+
+	assert.NoError(t, s.Snapshot())
+	assert.Equal(t, int64(0), fileSize(t, dir, logName))
+
+	assert.NoError(t, s.Delete("00"))
+	assert.NoError(t, s.Close())
+
+	s, err = Open(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, want[1:], values(s))
+	assert.NoError(t, s.Close())
+}
+
+func TestStore_TornWrite(t *testing.T) {
+	tests := []struct {
+		name string
+		tail []byte
+	}{
+		{
+			name: "partial header",
+			tail: []byte{5, 0, 0},
+		},
+		{
+			name: "partial payload",
+			tail: []byte{5, 0, 0, 0, 1, 2, 3, 4, opInsert, 'x'},
+		},
+		{
+			name: "checksum mismatch",
+			tail: []byte{2, 0, 0, 0, 1, 2, 3, 4, opInsert, 'x'},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tempDir(t)
+			defer os.RemoveAll(dir)
+
+			s, err := Open(dir)
+			assert.NoError(t, err)
+			assert.NoError(t, s.Insert("a"))
+			assert.NoError(t, s.Insert("b"))
+			assert.NoError(t, s.Close())
+			size := fileSize(t, dir, logName)
+
+			// Simulate a crash while writing a record
+			f, err := os.OpenFile(filepath.Join(dir, logName),
+				os.O_WRONLY|os.O_APPEND, 0644)
+			assert.NoError(t, err)
+			_, err = f.Write(tt.tail)
+			assert.NoError(t, err)
+			assert.NoError(t, f.Close())
+
+			// Assert that the torn record is discarded
+			s, err = Open(dir)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"a", "b"}, values(s))
+			assert.Equal(t, size, fileSize(t, dir, logName))
+
+			assert.NoError(t, s.Insert("c"))
+			assert.NoError(t, s.Close())
+
+			s, err = Open(dir)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"a", "b", "c"}, values(s))
+			assert.NoError(t, s.Close())
+		})
+	}
+}
+
+func TestStore_ReplayOntoSnapshot(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := Open(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Insert("a"))
+	assert.NoError(t, s.Insert("b"))
+	assert.NoError(t, s.Delete("a"))
+	log, err := ioutil.ReadFile(filepath.Join(dir, logName))
+	assert.NoError(t, err)
+	assert.NoError(t, s.Snapshot())
+	assert.NoError(t, s.Close())
+
+	// Simulate a crash between replacing the snapshot and truncating the log
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, logName), log, 0644))
+
+	s, err = Open(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, values(s))
+	assert.NoError(t, s.Close())
+}
+
+func TestStore_GroupCommit(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := OpenWithOptions(dir, Options{
+		SnapshotEvery: 50,
+		GroupCommit:   true,
+	})
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 40; j++ {
+				assert.NoError(t, s.Insert(fmt.Sprintf("%d-%02d", i, j)))
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.NoError(t, s.Close())
+
+	s, err = Open(dir)
+	assert.NoError(t, err)
+	assert.Len(t, values(s), 8*40)
+	assert.NoError(t, s.Close())
+}
+
+// values returns the values of the passed Store in ascending order.
+func values(s *Store) []string {
+	var values []string
+	s.Iterate(func(value string) bool {
+		values = append(values, value)
+		return true
+	})
+	return values
+}
+
+// tempDir returns a new temporary directory for a Store.
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "store")
+	assert.NoError(t, err)
+	return dir
+}
+
+// fileSize returns the size of the named file in the passed directory.
+func fileSize(t *testing.T, dir, name string) int64 {
+	info, err := os.Stat(filepath.Join(dir, name))
+	assert.NoError(t, err)
+	return info.Size()
+}
@@ -0,0 +1,324 @@
+// Package table provides an in-memory table of records with a primary
+// key and secondary indexes, each backed by a Treap.
+package table
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/austingebauer/go-treap"
+)
+
+// Primary is the name of the index of primary keys, which every Table has.
+const Primary = ""
+
+var (
+	// ErrDuplicateKey is returned when inserting a record whose
+	// primary key is already in the Table.
+	ErrDuplicateKey = errors.New("table: duplicate key")
+
+	// ErrNotFound is returned when a record with the given
+	// primary key isn't in the Table.
+	ErrNotFound = errors.New("table: record not found")
+
+	// ErrUnknownIndex is returned when querying an index
+	// that isn't declared in the Table.
+	ErrUnknownIndex = errors.New("table: unknown index")
+
+	// ErrIndexExists is returned when declaring an index
+	// with the name of an index already in the Table.
+	ErrIndexExists = errors.New("table: index exists")
+)
+
+// KeyFunc returns the key of a record of type T in an index. A key is a
+// string, int64, float64, time.Time or bool, and keys are ordered as the
+// columns of a treap.Tuple are.
+type KeyFunc[T any] func(record T) interface{}
+
+// Table holds records of type T by their primary keys and keeps a
+// secondary index of the records for each declared KeyFunc. Inserts,
+// updates and deletes keep every index consistent with the records.
+//
+// A record must not be modified while it's in the Table, since its keys
+// are extracted only when it's inserted or updated. A Table isn't safe
+// for concurrent use, and fn passed to a query must not modify the Table.
+type Table[T any] struct {
+	primary KeyFunc[T]
+	keys    *treap.Treap
+
+	// rows holds the rows of the Table by their encoded primary keys
+	rows map[string]*row[T]
+
+	// indexes holds the secondary indexes in the order they're declared,
+	// which is also the order of the entries of each row
+	indexes []*index[T]
+}
+
+// row is a record in a Table with its entries in the secondary indexes.
+type row[T any] struct {
+	record  T
+	entries []string
+}
+
+// index is a secondary index of a Table. Each entry is the
+// encoded tuple of the key of a record and its primary key.
+type index[T any] struct {
+	name    string
+	key     KeyFunc[T]
+	entries *treap.Treap
+}
+
+// New returns a new Table whose records have the
+// primary keys returned by the given KeyFunc.
+func New[T any](primary KeyFunc[T]) *Table[T] {
+	return &Table[T]{
+		primary: primary,
+		keys:    treap.NewTreap(),
+		rows:    make(map[string]*row[T]),
+	}
+}
+
+// AddIndex declares a secondary index with the given name whose records
+// have the keys returned by the given KeyFunc. Records already in the
+// Table are added to the index. The Table is unchanged if an error is
+// returned.
+func (t *Table[T]) AddIndex(name string, key KeyFunc[T]) error {
+	if name == Primary || t.index(name) != nil {
+		return fmt.Errorf("%w: %q", ErrIndexExists, name)
+	}
+
+	idx := &index[T]{
+		name:    name,
+		key:     key,
+		entries: treap.NewTreap(),
+	}
+
+	// extract every key before adding any, so a bad key changes nothing
+	entries := make(map[string]string, len(t.rows))
+	for pk, r := range t.rows {
+		entry, err := idx.entry(r.record, pk)
+		if err != nil {
+			return err
+		}
+		entries[pk] = entry
+	}
+
+	for pk, r := range t.rows {
+		r.entries = append(r.entries, entries[pk])
+		idx.entries.Insert(entries[pk])
+	}
+	t.indexes = append(t.indexes, idx)
+	return nil
+}
+
+// Len returns the number of records in the Table.
+func (t *Table[T]) Len() int {
+	return len(t.rows)
+}
+
+// Get returns the record with the given primary key,
+// or ErrNotFound if there is none.
+func (t *Table[T]) Get(key interface{}) (T, error) {
+	var zero T
+	pk, err := encodeKey(key)
+	if err != nil {
+		return zero, err
+	}
+
+	r, ok := t.rows[pk]
+	if !ok {
+		return zero, ErrNotFound
+	}
+	return r.record, nil
+}
+
+// Insert inserts the given record into the Table and its indexes.
+// Returns ErrDuplicateKey if a record with its primary key is
+// already in the Table.
+func (t *Table[T]) Insert(record T) error {
+	pk, entries, err := t.extract(record)
+	if err != nil {
+		return err
+	}
+	if _, ok := t.rows[pk]; ok {
+		return ErrDuplicateKey
+	}
+
+	t.rows[pk] = &row[T]{record: record, entries: entries}
+	t.keys.Insert(pk)
+	for i, idx := range t.indexes {
+		idx.entries.Insert(entries[i])
+	}
+	return nil
+}
+
+// Update replaces the record with the primary key of the given record,
+// moving it within the indexes whose keys changed. Returns ErrNotFound
+// if no record with its primary key is in the Table.
+func (t *Table[T]) Update(record T) error {
+	pk, entries, err := t.extract(record)
+	if err != nil {
+		return err
+	}
+	r, ok := t.rows[pk]
+	if !ok {
+		return ErrNotFound
+	}
+
+	for i, idx := range t.indexes {
+		if entries[i] != r.entries[i] {
+			idx.entries.Delete(r.entries[i])
+			idx.entries.Insert(entries[i])
+		}
+	}
+	r.record, r.entries = record, entries
+	return nil
+}
+
+// Delete deletes the record with the given primary key from the Table
+// and its indexes. Returns ErrNotFound if there is no such record.
+func (t *Table[T]) Delete(key interface{}) error {
+	pk, err := encodeKey(key)
+	if err != nil {
+		return err
+	}
+	r, ok := t.rows[pk]
+	if !ok {
+		return ErrNotFound
+	}
+
+	for i, idx := range t.indexes {
+		idx.entries.Delete(r.entries[i])
+	}
+	t.keys.Delete(pk)
+	delete(t.rows, pk)
+	return nil
+}
+
+// Lookup calls fn for each record whose key in the named index equals the
+// given key, in ascending order of primary key. Iteration stops early if
+// fn returns false.
+func (t *Table[T]) Lookup(name string, key interface{}, fn func(record T) bool) error {
+	entries, err := t.entries(name)
+	if err != nil {
+		return err
+	}
+	prefix, err := encodeKey(key)
+	if err != nil {
+		return err
+	}
+
+	return t.scan(name, func(visit func(entry string) bool) {
+		entries.PrefixScan(prefix, visit)
+	}, fn)
+}
+
+// Range calls fn for each record whose key in the named index is greater
+// than or equal to low and less than high, in ascending order of key and
+// then of primary key. A nil low or high leaves the range unbounded below
+// or above. Iteration stops early if fn returns false.
+func (t *Table[T]) Range(name string, low, high interface{}, fn func(record T) bool) error {
+	entries, err := t.entries(name)
+	if err != nil {
+		return err
+	}
+
+	var from, to string
+	if low != nil {
+		if from, err = encodeKey(low); err != nil {
+			return err
+		}
+	}
+	if high != nil {
+		if to, err = encodeKey(high); err != nil {
+			return err
+		}
+	}
+
+	return t.scan(name, func(visit func(entry string) bool) {
+		if high == nil {
+			entries.IterateFrom(from, visit)
+		} else {
+			entries.Range(from, to, visit)
+		}
+	}, fn)
+}
+
+// extract returns the encoded primary key of the passed record and its
+// entries in the secondary indexes of the Table.
+func (t *Table[T]) extract(record T) (string, []string, error) {
+	pk, err := encodeKey(t.primary(record))
+	if err != nil {
+		return "", nil, fmt.Errorf("table: primary key: %w", err)
+	}
+
+	entries := make([]string, len(t.indexes))
+	for i, idx := range t.indexes {
+		if entries[i], err = idx.entry(record, pk); err != nil {
+			return "", nil, err
+		}
+	}
+	return pk, entries, nil
+}
+
+// entries returns the Treap of the named index, whose values are
+// the encoded primary keys for the Primary index.
+func (t *Table[T]) entries(name string) (*treap.Treap, error) {
+	if name == Primary {
+		return t.keys, nil
+	}
+
+	idx := t.index(name)
+	if idx == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownIndex, name)
+	}
+	return idx.entries, nil
+}
+
+// scan calls fn for the record of each entry of the named index that
+// walk visits, until fn returns false. Returns an error if an entry
+// can't be decoded.
+func (t *Table[T]) scan(name string, walk func(visit func(entry string) bool), fn func(record T) bool) error {
+	var err error
+	walk(func(entry string) bool {
+		pk := entry
+		if name != Primary {
+			// the primary key is the last column of an entry
+			var tp treap.Tuple
+			if tp, err = treap.DecodeTuple(entry); err != nil {
+				return false
+			}
+			if pk, err = encodeKey(tp[len(tp)-1].Value); err != nil {
+				return false
+			}
+		}
+
+		return fn(t.rows[pk].record)
+	})
+	return err
+}
+
+// index returns the named secondary index, or nil if there is none.
+func (t *Table[T]) index(name string) *index[T] {
+	for _, idx := range t.indexes {
+		if idx.name == name {
+			return idx
+		}
+	}
+	return nil
+}
+
+// entry returns the entry of the passed record, which has the
+// passed encoded primary key, in the index.
+func (idx *index[T]) entry(record T, pk string) (string, error) {
+	key, err := encodeKey(idx.key(record))
+	if err != nil {
+		return "", fmt.Errorf("table: index %q: %w", idx.name, err)
+	}
+	return key + pk, nil
+}
+
+// encodeKey returns the passed key encoded as a single column tuple.
+func encodeKey(key interface{}) (string, error) {
+	return treap.Tuple{treap.Asc(key)}.Encode()
+}
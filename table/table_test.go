@@ -0,0 +1,268 @@
+package table
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/austingebauer/go-treap"
+	"github.com/stretchr/testify/assert"
+)
+
+type user struct {
+	id   string
+	team string
+	age  int64
+}
+
+func TestTable(t *testing.T) {
+	tbl := newUsers(t)
+	for _, u := range []user{
+		{id: "u1", team: "red", age: 30},
+		{id: "u2", team: "blue", age: 25},
+		{id: "u3", team: "red", age: 41},
+		{id: "u4", team: "green", age: 25},
+	} {
+		assert.NoError(t, tbl.Insert(u))
+	}
+	assert.Equal(t, 4, tbl.Len())
+
+	got, err := tbl.Get("u3")
+	assert.NoError(t, err)
+	assert.Equal(t, user{id: "u3", team: "red", age: 41}, got)
+
+	assert.Equal(t, []string{"u1", "u3"}, lookup(t, tbl, "team", "red"))
+	assert.Equal(t, []string{"u2", "u4"}, lookup(t, tbl, "age", int64(25)))
+	assert.Equal(t, []string{"u2"}, lookup(t, tbl, Primary, "u2"))
+	assert.Empty(t, lookup(t, tbl, "team", "yellow"))
+
+	// a record moves within the indexes whose keys changed
+	assert.NoError(t, tbl.Update(user{id: "u1", team: "blue", age: 30}))
+	assert.Equal(t, []string{"u3"}, lookup(t, tbl, "team", "red"))
+	assert.Equal(t, []string{"u1", "u2"}, lookup(t, tbl, "team", "blue"))
+
+	assert.NoError(t, tbl.Delete("u2"))
+	assert.Equal(t, []string{"u1"}, lookup(t, tbl, "team", "blue"))
+	assert.Equal(t, []string{"u4"}, lookup(t, tbl, "age", int64(25)))
+	assert.Equal(t, 3, tbl.Len())
+
+	_, err = tbl.Get("u2")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestTable_Range(t *testing.T) {
+	tbl := newUsers(t)
+	for i, age := range []int64{-5, 40, 3, 17, 25, 40, 9} {
+		id := "u" + strconv.Itoa(i)
+		assert.NoError(t, tbl.Insert(user{id: id, age: age}))
+	}
+
+	tests := []struct {
+		name  string
+		index string
+		low   interface{}
+		high  interface{}
+		want  []string
+	}{
+		{
+			name:  "bounded",
+			index: "age",
+			low:   int64(3),
+			high:  int64(25),
+			want:  []string{"u2", "u6", "u3"},
+		},
+		{
+			name:  "unbounded below",
+			index: "age",
+			high:  int64(9),
+			want:  []string{"u0", "u2"},
+		},
+		{
+			name:  "unbounded above",
+			index: "age",
+			low:   int64(25),
+			want:  []string{"u4", "u1", "u5"},
+		},
+		{
+			name:  "unbounded",
+			index: "age",
+			want:  []string{"u0", "u2", "u6", "u3", "u4", "u1", "u5"},
+		},
+		{
+			name:  "empty",
+			index: "age",
+			low:   int64(18),
+			high:  int64(25),
+		},
+		{
+			name:  "primary",
+			index: Primary,
+			low:   "u2",
+			high:  "u5",
+			want:  []string{"u2", "u3", "u4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := tbl.Range(tt.index, tt.low, tt.high, func(record user) bool {
+				got = append(got, record.id)
+				return true
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTable_Random(t *testing.T) {
+	tbl := newUsers(t)
+	want := make(map[string]user)
+	for i := 0; i < 2000; i++ {
+		u := user{
+			id:   "u" + strconv.Itoa(rand.Intn(200)),
+			team: strconv.Itoa(rand.Intn(5)),
+			age:  rand.Int63n(50),
+		}
+
+		_, exists := want[u.id]
+		switch rand.Intn(3) {
+		case 0:
+			err := tbl.Insert(u)
+			if exists {
+				assert.Equal(t, ErrDuplicateKey, err)
+				continue
+			}
+			assert.NoError(t, err)
+			want[u.id] = u
+		case 1:
+			err := tbl.Update(u)
+			if !exists {
+				assert.Equal(t, ErrNotFound, err)
+				continue
+			}
+			assert.NoError(t, err)
+			want[u.id] = u
+		default:
+			err := tbl.Delete(u.id)
+			if !exists {
+				assert.Equal(t, ErrNotFound, err)
+				continue
+			}
+			assert.NoError(t, err)
+			delete(want, u.id)
+		}
+	}
+	assert.Equal(t, len(want), tbl.Len())
+
+	// every index holds exactly the records, in order of key then id
+	byTeam := make(map[string][]string)
+	var byAge []user
+	for _, u := range want {
+		byTeam[u.team] = append(byTeam[u.team], u.id)
+		byAge = append(byAge, u)
+	}
+	for team, ids := range byTeam {
+		sort.Strings(ids)
+		assert.Equal(t, ids, lookup(t, tbl, "team", team))
+	}
+	sort.Slice(byAge, func(i, j int) bool {
+		if byAge[i].age != byAge[j].age {
+			return byAge[i].age < byAge[j].age
+		}
+		return byAge[i].id < byAge[j].id
+	})
+
+	var got []user
+	err := tbl.Range("age", nil, nil, func(record user) bool {
+		got = append(got, record)
+		return true
+	})
+	assert.NoError(t, err)
+	if len(byAge) == 0 {
+		byAge = nil
+	}
+	assert.Equal(t, byAge, got)
+}
+
+func TestTable_AddIndex(t *testing.T) {
+	tbl := New(func(record user) interface{} {
+		return record.id
+	})
+	assert.NoError(t, tbl.Insert(user{id: "a", team: "red"}))
+	assert.NoError(t, tbl.Insert(user{id: "b", team: "blue"}))
+
+	// records already in the Table are indexed
+	assert.NoError(t, tbl.AddIndex("team", func(record user) interface{} {
+		return record.team
+	}))
+	assert.Equal(t, []string{"b"}, lookup(t, tbl, "team", "blue"))
+
+	err := tbl.AddIndex("team", nil)
+	assert.True(t, errors.Is(err, ErrIndexExists))
+	err = tbl.AddIndex(Primary, nil)
+	assert.True(t, errors.Is(err, ErrIndexExists))
+
+	// an index with a bad key isn't added
+	err = tbl.AddIndex("bad", func(record user) interface{} {
+		return 1
+	})
+	assert.True(t, errors.Is(err, treap.ErrInvalidTuple))
+	err = tbl.Lookup("bad", "x", func(user) bool { return true })
+	assert.True(t, errors.Is(err, ErrUnknownIndex))
+}
+
+func TestTable_InvalidKey(t *testing.T) {
+	tbl := newUsers(t)
+	assert.NoError(t, tbl.AddIndex("bad", func(record user) interface{} {
+		if record.team == "bad" {
+			return struct{}{}
+		}
+		return record.team
+	}))
+	assert.NoError(t, tbl.Insert(user{id: "a", team: "red", age: 1}))
+
+	// a record with a bad key changes nothing
+	err := tbl.Insert(user{id: "b", team: "bad"})
+	assert.True(t, errors.Is(err, treap.ErrInvalidTuple))
+	err = tbl.Update(user{id: "a", team: "bad", age: 2})
+	assert.True(t, errors.Is(err, treap.ErrInvalidTuple))
+	assert.Equal(t, 1, tbl.Len())
+	assert.Equal(t, []string{"a"}, lookup(t, tbl, "age", int64(1)))
+
+	_, err = tbl.Get(1)
+	assert.True(t, errors.Is(err, treap.ErrInvalidTuple))
+	err = tbl.Delete(1)
+	assert.True(t, errors.Is(err, treap.ErrInvalidTuple))
+	err = tbl.Range("age", 1, nil, func(user) bool { return true })
+	assert.True(t, errors.Is(err, treap.ErrInvalidTuple))
+}
+
+// newUsers returns a Table of users keyed by id with
+// indexes of their teams and ages.
+func newUsers(t *testing.T) *Table[user] {
+	tbl := New(func(record user) interface{} {
+		return record.id
+	})
+	assert.NoError(t, tbl.AddIndex("team", func(record user) interface{} {
+		return record.team
+	}))
+	assert.NoError(t, tbl.AddIndex("age", func(record user) interface{} {
+		return record.age
+	}))
+	return tbl
+}
+
+// lookup returns the ids of the users whose key
+// in the named index equals the passed key.
+func lookup(t *testing.T, tbl *Table[user], name string, key interface{}) []string {
+	var ids []string
+	err := tbl.Lookup(name, key, func(record user) bool {
+		ids = append(ids, record.id)
+		return true
+	})
+	assert.NoError(t, err)
+	return ids
+}
@@ -3,8 +3,10 @@
 package treap
 
 import (
+	"hash"
 	"math"
 	"math/rand"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,9 +19,45 @@ const (
 	deletePriority = 0
 )
 
+// owners is the source of ownership tags for Treaps whose
+// nodes may be shared and must no longer be mutated in place.
+var owners uint64
+
 // Treap is a balanced binary search tree.
 type Treap struct {
 	root *node
+
+	// owner tags the nodes that the Treap may mutate in place.
+	// Nodes with any other tag are shared and are copied on write.
+	owner uint64
+
+	// version is incremented by each mutation of the Treap.
+	version uint64
+
+	// versions holds the retained versions of the Treap, in
+	// ascending order of version, when versioning is enabled.
+	versions   []snapshot
+	versioning bool
+
+	// history records the mutations of the Treap when history is enabled.
+	history *history
+
+	// seq is the sequence number of the last change published to
+	// the subscriptions of the Treap.
+	seq           uint64
+	subscriptions []*Subscription
+
+	// key and prf derive the priorities of a keyed Treap
+	// from its values. prf is nil if priorities are random.
+	key []byte
+	prf hash.Hash
+
+	// order orders the values of the Treap, or is nil for byte order.
+	order Order
+
+	// summaries caches the summaries of the subtrees of frozen nodes
+	// for reconciliation, or is nil if there are none.
+	summaries map[*node]summary
 }
 
 // node represents a value and its priority in a Treap.
@@ -28,6 +66,7 @@ type node struct {
 	priority int64
 	left     *node
 	right    *node
+	owner    uint64
 }
 
 // NewTreap returns a new Treap.
@@ -42,35 +81,58 @@ func (t *Treap) Search(value string) bool {
 		return false
 	}
 
-	return binarySearch(t.root, value) != nil
+	return binarySearch(t.root, value, t.order) != nil
+}
+
+// Iterate calls fn for each value in the Treap in ascending order.
+// Iteration stops early if fn returns false.
+func (t *Treap) Iterate(fn func(value string) bool) {
+	inorder(t.root, func(n *node) bool {
+		return fn(n.value)
+	})
 }
 
 // Insert inserts the given value into the Treap.
 func (t *Treap) Insert(value string) {
+	if binarySearch(t.root, value, t.order) != nil {
+		return
+	}
+
+	t.root = insert(t.root, value, t.priority(value), t.owner, t.order)
+	t.commit(operation{insert: true, value: value})
+}
+
+// randomPriority returns a random priority for a new node.
+func randomPriority() int64 {
 	rand.Seed(time.Now().UnixNano())
-	t.root = insert(t.root, value,
-		rand.Int63n(maxPriority-minPriority)+minPriority)
+	return rand.Int63n(maxPriority-minPriority) + minPriority
 }
 
-// insert inserts a node with the passed value and priority into the Treap.
-func insert(n *node, value string, priority int64) *node {
+// insert inserts a node with the passed value and priority into the Treap
+// in the passed order. Nodes on the insertion path that are not tagged
+// with the passed owner are copied rather than mutated.
+func insert(n *node, value string, priority int64, owner uint64, order Order) *node {
 	if n == nil {
 		return &node{
 			value:    value,
 			priority: priority,
+			owner:    owner,
 		}
 	}
 
 	if value == n.value {
 		return n
-	} else if value < n.value {
-		n.left = insert(n.left, value, priority)
-		if n.priority < n.left.priority {
+	}
+
+	n = n.mutable(owner)
+	if less(order, value, n.value) {
+		n.left = insert(n.left, value, priority, owner, order)
+		if n.left.outranks(n) {
 			n = rotateRight(n, n.left)
 		}
 	} else {
-		n.right = insert(n.right, value, priority)
-		if n.priority < n.right.priority {
+		n.right = insert(n.right, value, priority, owner, order)
+		if n.right.outranks(n) {
 			n = rotateLeft(n, n.right)
 		}
 	}
@@ -80,11 +142,18 @@ func insert(n *node, value string, priority int64) *node {
 
 // Delete deletes the given value from the Treap.
 func (t *Treap) Delete(value string) {
-	t.root = delete(t.root, value)
+	if binarySearch(t.root, value, t.order) == nil {
+		return
+	}
+
+	t.root = delete(t.root, value, t.owner, t.order)
+	t.commit(operation{insert: false, value: value})
 }
 
-// delete finds and deletes the node with the given value from the Treap.
-func delete(n *node, value string) *node {
+// delete finds and deletes the node with the given value from the Treap
+// in the passed order. Nodes on the deletion path that are not tagged
+// with the passed owner are copied rather than mutated.
+func delete(n *node, value string, owner uint64, order Order) *node {
 	if n == nil {
 		return nil
 	}
@@ -94,48 +163,119 @@ func delete(n *node, value string) *node {
 		return nil
 	}
 
+	n = n.mutable(owner)
 	if n.value == value {
 		n.priority = deletePriority
 
 		if n.right == nil && n.left != nil {
-			pivot := rotateRight(n, n.left)
-			pivot.right = delete(n, value)
+			pivot := rotateRight(n, n.left.mutable(owner))
+			pivot.right = delete(n, value, owner, order)
 			return pivot
 		} else if n.left == nil && n.right != nil {
-			pivot := rotateLeft(n, n.right)
-			pivot.left = delete(n, value)
+			pivot := rotateLeft(n, n.right.mutable(owner))
+			pivot.left = delete(n, value, owner, order)
 			return pivot
-		} else if n.right.priority > n.left.priority {
-			pivot := rotateLeft(n, n.right)
-			pivot.left = delete(n, value)
+		} else if n.right.outranks(n.left) {
+			pivot := rotateLeft(n, n.right.mutable(owner))
+			pivot.left = delete(n, value, owner, order)
 			return pivot
 		} else {
-			pivot := rotateRight(n, n.left)
-			pivot.right = delete(n, value)
+			pivot := rotateRight(n, n.left.mutable(owner))
+			pivot.right = delete(n, value, owner, order)
 			return pivot
 		}
 	}
 
-	if value < n.value {
-		n.left = delete(n.left, value)
+	if less(order, value, n.value) {
+		n.left = delete(n.left, value, owner, order)
 	} else {
-		n.right = delete(n.right, value)
+		n.right = delete(n.right, value, owner, order)
 	}
 
 	return n
 }
 
+// operation is a mutation that changed the contents of a Treap.
+type operation struct {
+	insert bool
+	value  string
+}
+
+// commit records the passed mutations of the Treap,
+// which together make a single new version.
+func (t *Treap) commit(ops ...operation) {
+	for _, op := range ops {
+		if t.history != nil {
+			t.history.record(op)
+		}
+		if op.insert {
+			t.publish(Inserted, op.value)
+		} else {
+			t.publish(Deleted, op.value)
+		}
+	}
+
+	t.advance()
+}
+
+// replace replaces the contents of the Treap with the tree rooted at
+// the passed node. The replacement can't be undone, so the history
+// of the Treap is cleared.
+func (t *Treap) replace(root *node) {
+	if len(t.subscriptions) > 0 {
+		t.publishReplace(t.root, root)
+	}
+
+	t.root = root
+	if t.history != nil {
+		t.history.clear()
+	}
+
+	t.advance()
+}
+
+// advance increments the version of the Treap, retaining
+// the new version if versioning is enabled.
+func (t *Treap) advance() {
+	t.version++
+	if t.versioning {
+		t.versions = append(t.versions, snapshot{
+			version: t.version,
+			root:    t.root,
+		})
+		t.freeze()
+	}
+}
+
+// freeze gives the Treap a new ownership tag, so that all of its
+// current nodes are shared from then on and are copied on write.
+func (t *Treap) freeze() {
+	t.owner = atomic.AddUint64(&owners, 1)
+}
+
+// mutable returns the node if it's tagged with the passed owner.
+// Otherwise, returns a copy of the node that is tagged with the owner.
+func (n *node) mutable(owner uint64) *node {
+	if n.owner == owner {
+		return n
+	}
+
+	c := *n
+	c.owner = owner
+	return &c
+}
+
 // binarySearch performs a binary search starting from the
-// passed node for the passed value.
+// passed node for the passed value in the passed order.
 // If the passed value is found, a pointer to the node with
 // the value is returned. Otherwise, nil is returned.
-func binarySearch(n *node, value string) *node {
+func binarySearch(n *node, value string, order Order) *node {
 	for n != nil {
 		if value == n.value {
 			return n
 		}
 
-		if value < n.value {
+		if less(order, value, n.value) {
 			n = n.left
 		} else {
 			n = n.right
@@ -145,6 +285,174 @@ func binarySearch(n *node, value string) *node {
 	return nil
 }
 
+// inorder calls fn for each node in the tree rooted at the passed
+// node in ascending order of value until fn returns false.
+// Returns false if the traversal was stopped early.
+func inorder(n *node, fn func(n *node) bool) bool {
+	var stack []*node
+	for n != nil || len(stack) > 0 {
+		for n != nil {
+			stack = append(stack, n)
+			n = n.left
+		}
+
+		n = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !fn(n) {
+			return false
+		}
+		n = n.right
+	}
+
+	return true
+}
+
+// cursor walks the tree rooted at a node in ascending order of value,
+// one node at a time.
+type cursor struct {
+	// stack holds the nodes whose values and right
+	// subtrees are yet to be visited
+	stack []*node
+	order Order
+}
+
+// newCursor returns a cursor positioned before the least value
+// of the tree rooted at the passed node, which is in the passed order.
+func newCursor(n *node, order Order) *cursor {
+	c := &cursor{order: order}
+	c.pushLeft(n)
+	return c
+}
+
+// next returns the node with the next value, and false if there is none.
+func (c *cursor) next() (*node, bool) {
+	if len(c.stack) == 0 {
+		return nil, false
+	}
+
+	n := c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+	c.pushLeft(n.right)
+	return n, true
+}
+
+// nextFrom returns the node with the next value that is greater than or
+// equal to the passed value, and false if there is none. The cursor must
+// walk the tree rooted at the passed node. If the next value is less,
+// the cursor seeks from the root, so a walk that skips most values
+// costs a logarithmic time per value returned.
+func (c *cursor) nextFrom(root *node, low string) (*node, bool) {
+	n, ok := c.next()
+	if !ok || !less(c.order, n.value, low) {
+		return n, ok
+	}
+
+	c.stack = c.stack[:0]
+	for n = root; n != nil; {
+		if !less(c.order, n.value, low) {
+			c.stack = append(c.stack, n)
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return c.next()
+}
+
+// pushLeft pushes the passed node and its chain of left descendants.
+func (c *cursor) pushLeft(n *node) {
+	for ; n != nil; n = n.left {
+		c.stack = append(c.stack, n)
+	}
+}
+
+// ascendFrom calls fn for each node in the tree rooted at the passed
+// node whose value is greater than or equal to the passed value in the
+// passed order, in ascending order of value until fn returns false.
+func ascendFrom(n *node, low string, order Order, fn func(n *node) bool) {
+	ascendWhere(n, func(value string) bool {
+		return !less(order, value, low)
+	}, fn)
+}
+
+// ascendWhere calls fn for each node in the tree rooted at the passed
+// node whose value is at or after a point, in ascending order of value
+// until fn returns false. atOrAfter reports whether a value is at or
+// after the point, and must be false for a prefix of the values in
+// ascending order and true for the rest.
+func ascendWhere(n *node, atOrAfter func(value string) bool, fn func(n *node) bool) {
+	// stack holds the nodes with values at or after the point
+	// whose values and right subtrees are yet to be visited
+	var stack []*node
+	for n != nil {
+		if atOrAfter(n.value) {
+			stack = append(stack, n)
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+
+	for len(stack) > 0 {
+		n = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !fn(n) {
+			return
+		}
+
+		for n = n.right; n != nil; n = n.left {
+			stack = append(stack, n)
+		}
+	}
+}
+
+// preorder calls fn for each node in the tree rooted at
+// the passed node in pre-order.
+func preorder(n *node, fn func(n *node)) {
+	if n == nil {
+		return
+	}
+
+	stack := []*node{n}
+	for len(stack) > 0 {
+		n = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		fn(n)
+
+		if n.right != nil {
+			stack = append(stack, n.right)
+		}
+		if n.left != nil {
+			stack = append(stack, n.left)
+		}
+	}
+}
+
+// levelorder calls fn for each node in the tree rooted at the passed
+// node in level order until fn returns an error, which is returned.
+func levelorder(n *node, fn func(n *node) error) error {
+	if n == nil {
+		return nil
+	}
+
+	queue := []*node{n}
+	for len(queue) > 0 {
+		n, queue = queue[0], queue[1:]
+		if err := fn(n); err != nil {
+			return err
+		}
+
+		if n.left != nil {
+			queue = append(queue, n.left)
+		}
+		if n.right != nil {
+			queue = append(queue, n.right)
+		}
+	}
+
+	return nil
+}
+
 // rotateRight does a tree rotation to the right given the passed root and pivot.
 // After the rotation, the root will be the right child of the pivot.
 // The pivot will be returned.
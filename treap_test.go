@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"github.com/stretchr/testify/assert"
 	"math/rand"
+	"sort"
 	"testing"
 	"time"
 )
@@ -464,7 +465,7 @@ func TestTreap_insert(t *testing.T) {
 			trp := &Treap{
 				root: tt.fields.root,
 			}
-			trp.root = insert(trp.root, tt.args.value, tt.args.priority)
+			trp.root = insert(trp.root, tt.args.value, tt.args.priority, trp.owner, nil)
 			assert.Equal(t, tt.want, trp.root)
 			assert.True(t, trp.Search(tt.args.value))
 		})
@@ -1398,7 +1399,7 @@ func TestTreap_delete(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			trp := NewTreap()
-			trp.root = delete(tt.fields.root, tt.args.value)
+			trp.root = delete(tt.fields.root, tt.args.value, trp.owner, nil)
 			assert.Equal(t, tt.want, trp.root)
 			assert.False(t, trp.Search(tt.args.value))
 		})
@@ -1635,7 +1636,7 @@ func Test_binarySearch(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			assert.Equal(t, tt.want, binarySearch(tt.args.n, tt.args.value))
+			assert.Equal(t, tt.want, binarySearch(tt.args.n, tt.args.value, nil))
 		})
 	}
 }
@@ -1703,3 +1704,23 @@ func hasTreapProperties(root *node) bool {
 		hasTreapProperties(root.left) &&
 		hasTreapProperties(root.right)
 }
+
+func TestTreap_Iterate(t *testing.T) {
+	trp := NewTreap()
+	inserted := fillTree(trp, 1000)
+
+	want := make([]string, 0, len(inserted))
+	for k := range inserted {
+		want = append(want, k)
+	}
+	sort.Strings(want)
+	assert.Equal(t, want, collect(trp))
+
+	// Assert that iteration stops when fn returns false
+	var got []string
+	trp.Iterate(func(value string) bool {
+		got = append(got, value)
+		return len(got) < 3
+	})
+	assert.Equal(t, want[:3], got)
+}
@@ -0,0 +1,259 @@
+package treap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// The tags that start the encoding of each type of column of a Tuple.
+// The tags order the types of a column whose values have mixed types.
+const (
+	tupleFalse  = 0x01
+	tupleTrue   = 0x02
+	tupleInt    = 0x03
+	tupleFloat  = 0x04
+	tupleTime   = 0x05
+	tupleString = 0x06
+)
+
+// ErrInvalidTuple is returned when decoding a key that isn't an
+// encoded Tuple, or encoding a Tuple with an unsupported column.
+var ErrInvalidTuple = errors.New("treap: invalid tuple")
+
+// Column is a column of a Tuple. Its value is a string, int64, float64,
+// time.Time or bool, and it's ordered in ascending order unless Desc is
+// true.
+type Column struct {
+	Value interface{}
+	Desc  bool
+}
+
+// Asc returns a Column with the given value in ascending order.
+func Asc(value interface{}) Column {
+	return Column{Value: value}
+}
+
+// Desc returns a Column with the given value in descending order.
+func Desc(value interface{}) Column {
+	return Column{Value: value, Desc: true}
+}
+
+// Tuple is a composite key of columns, which is encoded as a string
+// whose byte order is the order of the Tuple. Tuples are ordered by
+// their first column, then by their second, and so on, with a Tuple
+// that is a prefix of another ordered before it. Each column is ordered
+// by its value in its direction: strings by their bytes, numbers by
+// their numeric value, times chronologically, and false before true.
+//
+// The encoding of a Tuple starts with the encoding of each of its
+// prefixes, so a Treap in byte order can be scanned for the keys that
+// start with the same leading columns with PrefixScan or ScanTuples,
+// and a range of the next column with Range.
+//
+// Each column is encoded as a tag byte for its type followed by its
+// value, with every byte inverted if it's in descending order. A string
+// is its bytes with each 0x00 byte escaped as 0x00 0xff, followed by 0x00
+// 0x01. An int64 is 8 bytes big endian with the sign bit flipped. A
+// float64 is its IEEE 754 bits big endian with the sign bit flipped, or
+// every bit flipped if it's negative. A time.Time is its Unix time in
+// seconds encoded as an int64, followed by its nanoseconds as 4 bytes
+// big endian. A bool is only its tag.
+type Tuple []Column
+
+// Encode returns the key that the Tuple is encoded as.
+// Returns ErrInvalidTuple if a column has an unsupported type.
+func (tp Tuple) Encode() (string, error) {
+	var buf []byte
+	for i, c := range tp {
+		start := len(buf)
+
+		switch v := c.Value.(type) {
+		case string:
+			buf = append(buf, tupleString)
+			for j := 0; j < len(v); j++ {
+				buf = append(buf, v[j])
+				if v[j] == 0x00 {
+					buf = append(buf, 0xff)
+				}
+			}
+			buf = append(buf, 0x00, 0x01)
+		case int64:
+			buf = append(buf, tupleInt)
+			buf = appendUint64(buf, uint64(v)^(1<<63))
+		case float64:
+			if v == 0 {
+				// negative zero is ordered as zero
+				v = 0
+			}
+			bits := math.Float64bits(v)
+			if bits&(1<<63) != 0 {
+				bits = ^bits
+			} else {
+				bits ^= 1 << 63
+			}
+			buf = append(buf, tupleFloat)
+			buf = appendUint64(buf, bits)
+		case time.Time:
+			buf = append(buf, tupleTime)
+			buf = appendUint64(buf, uint64(v.Unix())^(1<<63))
+			var nanos [4]byte
+			binary.BigEndian.PutUint32(nanos[:], uint32(v.Nanosecond()))
+			buf = append(buf, nanos[:]...)
+		case bool:
+			if v {
+				buf = append(buf, tupleTrue)
+			} else {
+				buf = append(buf, tupleFalse)
+			}
+		default:
+			return "", fmt.Errorf("%w: column %d has unsupported type %T",
+				ErrInvalidTuple, i, c.Value)
+		}
+
+		if c.Desc {
+			for j := start; j < len(buf); j++ {
+				buf[j] = ^buf[j]
+			}
+		}
+	}
+
+	return string(buf), nil
+}
+
+// DecodeTuple decodes the Tuple that the given key is the encoding of.
+// Times are decoded in UTC.
+// Returns ErrInvalidTuple if the key isn't the encoding of a Tuple.
+func DecodeTuple(key string) (Tuple, error) {
+	var tp Tuple
+	for len(key) > 0 {
+		// the tag of a column in descending order is inverted,
+		// which sets its high bit
+		var mask byte
+		if key[0]&0x80 != 0 {
+			mask = 0xff
+		}
+		tag := key[0] ^ mask
+		key = key[1:]
+
+		c := Column{Desc: mask != 0}
+		switch tag {
+		case tupleString:
+			var value []byte
+			for {
+				if len(key) < 2 && (len(key) == 0 || key[0]^mask == 0x00) {
+					return nil, tupleError(len(tp), "unterminated string")
+				}
+
+				b := key[0] ^ mask
+				key = key[1:]
+				if b != 0x00 {
+					value = append(value, b)
+					continue
+				}
+
+				next := key[0] ^ mask
+				key = key[1:]
+				if next == 0x01 {
+					break
+				}
+				if next != 0xff {
+					return nil, tupleError(len(tp), "bad escape in string")
+				}
+				value = append(value, 0x00)
+			}
+			c.Value = string(value)
+		case tupleInt:
+			bits, ok := readUint64(&key, mask)
+			if !ok {
+				return nil, tupleError(len(tp), "short int64")
+			}
+			c.Value = int64(bits ^ (1 << 63))
+		case tupleFloat:
+			bits, ok := readUint64(&key, mask)
+			if !ok {
+				return nil, tupleError(len(tp), "short float64")
+			}
+			if bits&(1<<63) != 0 {
+				bits ^= 1 << 63
+			} else {
+				bits = ^bits
+			}
+			c.Value = math.Float64frombits(bits)
+		case tupleTime:
+			bits, ok := readUint64(&key, mask)
+			if !ok || len(key) < 4 {
+				return nil, tupleError(len(tp), "short time")
+			}
+			var nanos [4]byte
+			for j := range nanos {
+				nanos[j] = key[j] ^ mask
+			}
+			key = key[4:]
+			c.Value = time.Unix(int64(bits^(1<<63)),
+				int64(binary.BigEndian.Uint32(nanos[:]))).UTC()
+		case tupleFalse:
+			c.Value = false
+		case tupleTrue:
+			c.Value = true
+		default:
+			return nil, tupleError(len(tp), fmt.Sprintf("unknown tag %#x", tag))
+		}
+
+		tp = append(tp, c)
+	}
+
+	return tp, nil
+}
+
+// ScanTuples calls fn for each key in the Treap that is the encoding of
+// a Tuple starting with the columns of the given prefix, decoded, in
+// ascending order. Iteration stops early if fn returns false. The Treap
+// must be in byte order.
+// Returns ErrInvalidTuple if the prefix has an unsupported column or a
+// key with the prefix isn't the encoding of a Tuple.
+func (t *Treap) ScanTuples(prefix Tuple, fn func(key Tuple) bool) error {
+	encoded, err := prefix.Encode()
+	if err != nil {
+		return err
+	}
+
+	t.PrefixScan(encoded, func(value string) bool {
+		var tp Tuple
+		if tp, err = DecodeTuple(value); err != nil {
+			return false
+		}
+		return fn(tp)
+	})
+	return err
+}
+
+// appendUint64 appends the passed integer big endian to the passed buffer.
+func appendUint64(buf []byte, x uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], x)
+	return append(buf, b[:]...)
+}
+
+// readUint64 reads a big endian integer, with its bytes xored with the
+// passed mask, from the start of the passed key and advances the key.
+// Returns false if the key is too short.
+func readUint64(key *string, mask byte) (uint64, bool) {
+	if len(*key) < 8 {
+		return 0, false
+	}
+
+	var b [8]byte
+	for i := range b {
+		b[i] = (*key)[i] ^ mask
+	}
+	*key = (*key)[8:]
+	return binary.BigEndian.Uint64(b[:]), true
+}
+
+// tupleError returns an ErrInvalidTuple for the passed column.
+func tupleError(column int, reason string) error {
+	return fmt.Errorf("%w: column %d: %s", ErrInvalidTuple, column, reason)
+}
@@ -0,0 +1,274 @@
+package treap
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTuple_Encode_Order(t *testing.T) {
+	base := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	tests := []struct {
+		name string
+		less Tuple
+		more Tuple
+	}{
+		{
+			name: "strings",
+			less: Tuple{Asc("a")},
+			more: Tuple{Asc("b")},
+		},
+		{
+			name: "string prefix",
+			less: Tuple{Asc("a")},
+			more: Tuple{Asc("a\x00")},
+		},
+		{
+			name: "string before next column",
+			less: Tuple{Asc("a"), Asc("z")},
+			more: Tuple{Asc("ab"), Asc("a")},
+		},
+		{
+			name: "descending strings",
+			less: Tuple{Desc("ab")},
+			more: Tuple{Desc("a")},
+		},
+		{
+			name: "negative ints",
+			less: Tuple{Asc(int64(-10))},
+			more: Tuple{Asc(int64(-9))},
+		},
+		{
+			name: "int sign",
+			less: Tuple{Asc(int64(math.MinInt64))},
+			more: Tuple{Asc(int64(0))},
+		},
+		{
+			name: "descending ints",
+			less: Tuple{Desc(int64(10))},
+			more: Tuple{Desc(int64(9))},
+		},
+		{
+			name: "negative floats",
+			less: Tuple{Asc(-2.5)},
+			more: Tuple{Asc(-1.5)},
+		},
+		{
+			name: "float sign",
+			less: Tuple{Asc(-0.1)},
+			more: Tuple{Asc(0.1)},
+		},
+		{
+			name: "infinite floats",
+			less: Tuple{Asc(math.Inf(-1))},
+			more: Tuple{Asc(math.Inf(1))},
+		},
+		{
+			name: "times",
+			less: Tuple{Asc(base)},
+			more: Tuple{Asc(base.Add(time.Nanosecond))},
+		},
+		{
+			name: "times before the epoch",
+			less: Tuple{Asc(time.Unix(-2, 999999999))},
+			more: Tuple{Asc(time.Unix(-1, 0))},
+		},
+		{
+			name: "bools",
+			less: Tuple{Asc(false)},
+			more: Tuple{Asc(true)},
+		},
+		{
+			name: "descending bools",
+			less: Tuple{Desc(true)},
+			more: Tuple{Desc(false)},
+		},
+		{
+			name: "prefix tuple",
+			less: Tuple{Asc("a"), Desc(int64(1))},
+			more: Tuple{Asc("a"), Desc(int64(1)), Asc(false)},
+		},
+		{
+			name: "second column decides",
+			less: Tuple{Asc("a"), Desc(int64(2)), Asc("z")},
+			more: Tuple{Asc("a"), Desc(int64(1)), Asc("a")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			less, err := tt.less.Encode()
+			assert.NoError(t, err)
+			more, err := tt.more.Encode()
+			assert.NoError(t, err)
+			assert.True(t, less < more)
+		})
+	}
+}
+
+func TestTuple_Encode_Random(t *testing.T) {
+	type row struct {
+		name  string
+		score float64
+		at    int64
+	}
+
+	var rows []row
+	trp := NewTreap()
+	for i := 0; i < 1000; i++ {
+		r := row{
+			name:  string(rune('a' + rand.Intn(3))),
+			score: float64(rand.Intn(200)-100) / 4,
+			at:    rand.Int63n(1000) - 500,
+		}
+		rows = append(rows, r)
+
+		key, err := Tuple{Asc(r.name), Desc(r.score), Asc(r.at)}.Encode()
+		assert.NoError(t, err)
+		trp.Insert(key)
+	}
+
+	// the keys are ordered by name, then descending score, then time
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		return a.at < b.at
+	})
+
+	var got []row
+	trp.Iterate(func(value string) bool {
+		tp, err := DecodeTuple(value)
+		assert.NoError(t, err)
+		got = append(got, row{
+			name:  tp[0].Value.(string),
+			score: tp[1].Value.(float64),
+			at:    tp[2].Value.(int64),
+		})
+		return true
+	})
+
+	var want []row
+	for i, r := range rows {
+		if i == 0 || r != rows[i-1] {
+			want = append(want, r)
+		}
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestDecodeTuple(t *testing.T) {
+	at := time.Date(1960, 5, 6, 7, 8, 9, 10, time.UTC)
+	tests := []struct {
+		name  string
+		tuple Tuple
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "every type ascending",
+			tuple: Tuple{
+				Asc("a\x00b\xff"), Asc(int64(-5)), Asc(-1.25), Asc(at), Asc(true),
+			},
+		},
+		{
+			name: "every type descending",
+			tuple: Tuple{
+				Desc("a\x00b\xff"), Desc(int64(-5)), Desc(-1.25), Desc(at), Desc(false),
+			},
+		},
+		{
+			name:  "empty string",
+			tuple: Tuple{Asc(""), Desc("")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := tt.tuple.Encode()
+			assert.NoError(t, err)
+
+			got, err := DecodeTuple(key)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.tuple, got)
+		})
+	}
+}
+
+func TestDecodeTuple_Invalid(t *testing.T) {
+	valid, err := Tuple{Asc("abc"), Desc(int64(1)), Asc(time.Unix(0, 0))}.Encode()
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{name: "unknown tag", key: "\x7f"},
+		{name: "unterminated string", key: "\x06abc"},
+		{name: "bad escape", key: "\x06a\x00\x02"},
+		{name: "short int", key: "\x03\x00"},
+		{name: "short float", key: "\x04"},
+		{name: "short time", key: valid[:len(valid)-1]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeTuple(tt.key)
+			assert.True(t, errors.Is(err, ErrInvalidTuple))
+		})
+	}
+}
+
+func TestTuple_Encode_Unsupported(t *testing.T) {
+	_, err := Tuple{Asc("a"), Asc(1)}.Encode()
+	assert.True(t, errors.Is(err, ErrInvalidTuple))
+	assert.True(t, strings.Contains(err.Error(), "column 1"))
+}
+
+func TestTreap_ScanTuples(t *testing.T) {
+	trp := NewTreap()
+	for _, tp := range []Tuple{
+		{Asc("acme"), Asc(int64(1)), Asc("alice")},
+		{Asc("acme"), Asc(int64(2)), Asc("bob")},
+		{Asc("acme"), Asc(int64(10)), Asc("carol")},
+		{Asc("acme2"), Asc(int64(1)), Asc("dave")},
+		{Asc("globex"), Asc(int64(1)), Asc("erin")},
+	} {
+		key, err := tp.Encode()
+		assert.NoError(t, err)
+		trp.Insert(key)
+	}
+
+	var names []string
+	err := trp.ScanTuples(Tuple{Asc("acme")}, func(key Tuple) bool {
+		names = append(names, key[2].Value.(string))
+		return true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob", "carol"}, names)
+
+	// a range of the next column is a range of the keys
+	low, err := Tuple{Asc("acme"), Asc(int64(2))}.Encode()
+	assert.NoError(t, err)
+	high, err := Tuple{Asc("acme"), Asc(int64(11))}.Encode()
+	assert.NoError(t, err)
+	names = nil
+	trp.Range(low, high, func(value string) bool {
+		key, err := DecodeTuple(value)
+		assert.NoError(t, err)
+		names = append(names, key[2].Value.(string))
+		return true
+	})
+	assert.Equal(t, []string{"bob", "carol"}, names)
+
+	err = trp.ScanTuples(Tuple{Asc(struct{}{})}, func(Tuple) bool { return true })
+	assert.True(t, errors.Is(err, ErrInvalidTuple))
+}
@@ -0,0 +1,100 @@
+package treap
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrVersionUnavailable is returned when reading a version of
+// the Treap that isn't retained.
+var ErrVersionUnavailable = errors.New("treap: version unavailable")
+
+// snapshot is the root of a Treap as of a version.
+// The nodes reachable from the root are shared and never mutated.
+type snapshot struct {
+	version uint64
+	root    *node
+}
+
+// Version returns the current version of the Treap.
+// The version is incremented by each Insert or Delete that
+// changes the contents of the Treap.
+func (t *Treap) Version() uint64 {
+	return t.version
+}
+
+// EnableVersioning makes the Treap retain each of its versions,
+// starting with the current version, so that they can be read with
+// SearchAt and IterateAt. Versions share all unchanged nodes, so each
+// mutation retains only the nodes on its path from the root.
+// Retained versions are released with ReleaseBefore.
+func (t *Treap) EnableVersioning() {
+	if t.versioning {
+		return
+	}
+
+	t.versioning = true
+	t.versions = append(t.versions, snapshot{
+		version: t.version,
+		root:    t.root,
+	})
+	t.freeze()
+}
+
+// SearchAt returns true if the given value was in the Treap as
+// of the given version. Otherwise, returns false.
+// Returns ErrVersionUnavailable if the version isn't retained.
+func (t *Treap) SearchAt(value string, version uint64) (bool, error) {
+	root, err := t.rootAt(version)
+	if err != nil {
+		return false, err
+	}
+
+	return binarySearch(root, value, t.order) != nil, nil
+}
+
+// IterateAt calls fn for each value that was in the Treap as of the
+// given version in ascending order. Iteration stops early if fn
+// returns false.
+// Returns ErrVersionUnavailable if the version isn't retained.
+func (t *Treap) IterateAt(version uint64, fn func(value string) bool) error {
+	root, err := t.rootAt(version)
+	if err != nil {
+		return err
+	}
+
+	inorder(root, func(n *node) bool {
+		return fn(n.value)
+	})
+	return nil
+}
+
+// ReleaseBefore releases the retained versions older than the given
+// watermark. Versions at or after the watermark remain readable.
+func (t *Treap) ReleaseBefore(watermark uint64) {
+	i := sort.Search(len(t.versions), func(i int) bool {
+		return t.versions[i].version > watermark
+	})
+
+	// keep the version that the watermark itself reads from
+	if i > 0 {
+		i--
+	}
+	t.versions = append([]snapshot(nil), t.versions[i:]...)
+}
+
+// rootAt returns the root of the Treap as of the given version.
+func (t *Treap) rootAt(version uint64) (*node, error) {
+	if version == t.version {
+		return t.root, nil
+	}
+
+	i := sort.Search(len(t.versions), func(i int) bool {
+		return t.versions[i].version > version
+	})
+	if i == 0 || version > t.version {
+		return nil, ErrVersionUnavailable
+	}
+
+	return t.versions[i-1].root, nil
+}
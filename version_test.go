@@ -0,0 +1,144 @@
+package treap
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreap_Version(t *testing.T) {
+	trp := NewTreap()
+	assert.Equal(t, uint64(0), trp.Version())
+
+	trp.Insert("a")
+	trp.Insert("b")
+	assert.Equal(t, uint64(2), trp.Version())
+
+	// mutations that don't change the contents don't get a version
+	trp.Insert("a")
+	trp.Delete("z")
+	assert.Equal(t, uint64(2), trp.Version())
+
+	trp.Delete("a")
+	assert.Equal(t, uint64(3), trp.Version())
+}
+
+func TestTreap_SearchAt(t *testing.T) {
+	trp := NewTreap()
+	trp.Insert("a")
+	trp.EnableVersioning()
+	trp.Insert("b")
+	trp.Insert("c")
+	trp.Delete("a")
+
+	tests := []struct {
+		name    string
+		value   string
+		version uint64
+		want    bool
+		wantErr error
+	}{
+		{
+			name:    "value present at enabling version",
+			value:   "a",
+			version: 1,
+			want:    true,
+		},
+		{
+			name:    "value not yet inserted",
+			value:   "c",
+			version: 2,
+			want:    false,
+		},
+		{
+			name:    "value inserted",
+			value:   "c",
+			version: 3,
+			want:    true,
+		},
+		{
+			name:    "value deleted",
+			value:   "a",
+			version: 4,
+			want:    false,
+		},
+		{
+			name:    "version before versioning was enabled",
+			value:   "a",
+			version: 0,
+			wantErr: ErrVersionUnavailable,
+		},
+		{
+			name:    "version in the future",
+			value:   "a",
+			version: 5,
+			wantErr: ErrVersionUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := trp.SearchAt(tt.value, tt.version)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTreap_IterateAt(t *testing.T) {
+	trp := NewTreap()
+	trp.EnableVersioning()
+
+	// Record the sorted contents of the treap at every version
+	want := make(map[uint64][]string)
+	inserted := fillTree(trp, 200)
+	for k := range inserted {
+		trp.Delete(k)
+		if len(want) < 50 {
+			want[trp.Version()] = collect(trp)
+		}
+	}
+
+	for version, values := range want {
+		var got []string
+		err := trp.IterateAt(version, func(value string) bool {
+			got = append(got, value)
+			return true
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, values, got)
+		assert.True(t, sort.StringsAreSorted(got))
+	}
+	assert.Nil(t, trp.root)
+}
+
+func TestTreap_ReleaseBefore(t *testing.T) {
+	trp := NewTreap()
+	trp.EnableVersioning()
+	for _, v := range []string{"a", "b", "c", "d"} {
+		trp.Insert(v)
+	}
+
+	trp.ReleaseBefore(3)
+
+	_, err := trp.SearchAt("a", 2)
+	assert.Equal(t, ErrVersionUnavailable, err)
+
+	found, err := trp.SearchAt("c", 3)
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	found, err = trp.SearchAt("d", 3)
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+// collect returns the values of the passed Treap in ascending order.
+func collect(trp *Treap) []string {
+	var values []string
+	trp.Iterate(func(value string) bool {
+		values = append(values, value)
+		return true
+	})
+	return values
+}